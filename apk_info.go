@@ -4,40 +4,76 @@ import (
 	"bytes"
 	"encoding/xml"
 	"fmt"
+	"strconv"
 
 	"github.com/avast/apkparser"
 )
 
 type manifest struct {
 	XMLName     xml.Name `xml:"manifest"`
+	UsesSDK     usesSDK
 	Application application
 }
 
+type usesSDK struct {
+	XMLName       xml.Name `xml:"uses-sdk"`
+	MinSDKVersion string   `xml:"minSdkVersion,attr"` // defaults to 1
+}
+
 type application struct {
 	XMLName           xml.Name `xml:"application"`
 	ExtractNativeLibs bool     `xml:"extractNativeLibs,attr"` // defaults to false
 }
 
-func parseAPKextractNativeLibs(apkPath string) (bool, error) {
+func parseAPKManifest(apkPath string) (manifest, error) {
 	var manifestContent bytes.Buffer
 	enc := xml.NewEncoder(&manifestContent)
 	enc.Indent("", "\t")
 
 	zipErr, resErr, manErr := apkparser.ParseApk(apkPath, enc)
 	if zipErr != nil {
-		return false, fmt.Errorf("failed to unzip the APK: %s", zipErr)
+		return manifest{}, fmt.Errorf("failed to unzip the APK: %s", zipErr)
 	}
 	if resErr != nil {
-		return false, fmt.Errorf("failed to parse resources: %s", resErr)
+		return manifest{}, fmt.Errorf("failed to parse resources: %s", resErr)
 	}
 	if manErr != nil {
-		return false, fmt.Errorf("failed to parse AndroidManifest.xml: %s", manErr)
+		return manifest{}, fmt.Errorf("failed to parse AndroidManifest.xml: %s", manErr)
+	}
+
+	var m manifest
+	if err := xml.Unmarshal(manifestContent.Bytes(), &m); err != nil {
+		return manifest{}, fmt.Errorf("failed to unmarshal AndroidManifest.xml: %s", err)
 	}
+	return m, nil
+}
 
-	var manifest manifest
-	if err := xml.Unmarshal(manifestContent.Bytes(), &manifest); err != nil {
-		return false, fmt.Errorf("failed to unmarshal AndroidManifest.xml: %s", err)
+func parseAPKextractNativeLibs(apkPath string) (bool, error) {
+	manifest, err := parseAPKManifest(apkPath)
+	if err != nil {
+		return false, err
 	}
 
 	return manifest.Application.ExtractNativeLibs, nil
 }
+
+func parseAPKMinSDKVersion(apkPath string) (int, error) {
+	manifest, err := parseAPKManifest(apkPath)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseMinSDKVersion(manifest.UsesSDK.MinSDKVersion)
+}
+
+// parseMinSDKVersion parses the minSdkVersion attribute of the manifest, a codename of a preview release is not supported.
+func parseMinSDKVersion(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	minSDKVersion, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported minSdkVersion: %s", value)
+	}
+	return minSDKVersion, nil
+}
@@ -5,25 +5,28 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/bitrise-io/go-utils/command"
 	"github.com/bitrise-io/go-utils/errorutil"
 	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
 )
 
-func createSignerSchemeCmd(signerScheme string) string {
-	switch signerScheme {
-	case "automatic":
-		return ""
-	case "v2":
-		return "--v2-signing-enabled"
-	case "v3":
-		return "--v3-signing-enabled"
-	case "v4":
-		return "--v4-signing-enabled"
-	default:
-		return ""
+// defaultRotationMinSDKVersion is the SDK level the rotated signer is used from by default (Android 13).
+const defaultRotationMinSDKVersion = 33
+
+// createSignerSchemesCmdSlice returns the --vN-signing-enabled flag of every scheme,
+// the schemes which are not set are resolved for an APK of minSDKVersion.
+func createSignerSchemesCmdSlice(schemes SignerSchemes, minSDKVersion int) []string {
+	resolved := schemes.resolve(minSDKVersion)
+	var cmdSlice []string
+	for _, version := range signerSchemeVersions {
+		cmdSlice = append(cmdSlice, fmt.Sprintf("--v%d-signing-enabled", version), strconv.FormatBool(resolved[version]))
 	}
+	return cmdSlice
 }
 
 func createKeystoreCmdSlice(configuration *KeystoreSignatureConfiguration) ([]string, error) {
@@ -40,26 +43,47 @@ func createKeystoreCmdSlice(configuration *KeystoreSignatureConfiguration) ([]st
 		configuration.alias,
 	}
 
-	if configuration.aliasPassword != "" {
-		cmdSlice = append(cmdSlice, "--key-pass", "pass:"+configuration.aliasPassword)
+	if configuration.keystoreType != keystore.TypeUnknown {
+		cmdSlice = append(cmdSlice, "--ks-type", configuration.keystoreType.StoreType())
+	}
+	if providerClass := configuration.keystoreType.ProviderClass(); providerClass != "" {
+		cmdSlice = append(cmdSlice, "--provider-class", providerClass)
+	}
+
+	if keyPassword, _ := keystore.SignerKeyPassword(configuration.keystoreType, configuration.keystorePassword, configuration.aliasPassword); keyPassword != "" {
+		cmdSlice = append(cmdSlice, "--key-pass", "pass:"+keyPassword)
 	}
 
 	return cmdSlice, nil
 }
 
-func (configuration SignatureConfiguration) createSignCmd(buildArtifactPth string, destBuildArtifactPth string) ([]string, error) {
-	var signatureSlice []string
-	var err error
+func createKeyCertificateCmdSlice(configuration *KeyCertificateSignatureConfiguration) ([]string, error) {
+	if configuration == nil {
+		return []string{}, errors.New("Invalid Key Certificate Configuration")
+	}
 
-	switch configuration.signatureType {
+	return []string{
+		"--key",
+		configuration.keyPth,
+		"--cert",
+		configuration.certificatePth,
+	}, nil
+}
+
+func createSignerCmdSlice(signer SignerConfiguration) ([]string, error) {
+	switch signer.signatureType {
 	case KeystoreSignatureType:
-		signatureSlice, err = createKeystoreCmdSlice(configuration.keystoreConfiguration)
+		return createKeystoreCmdSlice(signer.keystoreConfiguration)
+	case KeyCertificateSignatureType:
+		return createKeyCertificateCmdSlice(signer.keyCertificateConfiguration)
 	default:
-		err = fmt.Errorf("invalid signature type: %s", configuration.signatureType)
+		return nil, fmt.Errorf("invalid signature type: %s", signer.signatureType)
 	}
+}
 
-	if err != nil {
-		return nil, err
+func (configuration SignatureConfiguration) createSignCmd(buildArtifactPth string, destBuildArtifactPth string) ([]string, error) {
+	if len(configuration.signers) == 0 {
+		return nil, errors.New("no signer configured")
 	}
 
 	cmdSlice := []string{
@@ -73,17 +97,47 @@ func (configuration SignatureConfiguration) createSignCmd(buildArtifactPth strin
 		configuration.debuggablePermitted,
 	}
 
-	scheme := createSignerSchemeCmd(configuration.signerScheme)
+	cmdSlice = append(cmdSlice, createSignerSchemesCmdSlice(configuration.signerSchemes, configuration.apkMinSDKVersion)...)
+	cmdSlice = append(cmdSlice, createSDKVersionsCmdSlice(configuration.minSDKVersion, configuration.maxSDKVersion)...)
 
-	if scheme != "" {
-		cmdSlice = append(cmdSlice, scheme)
+	if rotation := configuration.rotationConfiguration; rotation != nil {
+		cmdSlice = append(cmdSlice, createRotationCmdSlice(*rotation)...)
 	}
 
-	cmdSlice = append(cmdSlice, signatureSlice...)
+	for i, signer := range configuration.signers {
+		signerSlice, err := createSignerCmdSlice(signer)
+		if err != nil {
+			return nil, fmt.Errorf("signer %d: %s", i+1, err)
+		}
+		if i > 0 {
+			cmdSlice = append(cmdSlice, "--next-signer")
+		}
+		cmdSlice = append(cmdSlice, signerSlice...)
+	}
+
+	if stamp := configuration.sourceStampConfiguration; stamp != nil {
+		stampSlice, err := createKeystoreCmdSlice(&stamp.signer)
+		if err != nil {
+			return nil, fmt.Errorf("stamp signer: %s", err)
+		}
+		cmdSlice = append(cmdSlice, "--stamp-signer")
+		cmdSlice = append(cmdSlice, stampSlice...)
+	}
 
 	return cmdSlice, nil
 }
 
+func createRotationCmdSlice(rotation RotationConfiguration) []string {
+	cmdSlice := []string{"--lineage", rotation.lineagePth}
+	if rotation.minSDKVersion > 0 {
+		cmdSlice = append(cmdSlice, "--rotation-min-sdk-version", strconv.Itoa(rotation.minSDKVersion))
+	}
+	if rotation.targetsDevRelease {
+		cmdSlice = append(cmdSlice, "--rotation-targets-dev-release")
+	}
+	return cmdSlice
+}
+
 // SignBuildArtifact buildArtifactPth
 // This signs the provided APK, stripping out any pre-existing signatures. Signing
 // is performed using one or more signers, each represented by an asymmetric key
@@ -113,12 +167,165 @@ func (configuration SignatureConfiguration) SignBuildArtifact(buildArtifactPth s
 // checks whether the APK will verify on all Android platform versions supported
 // by the APK (as declared using minSdkVersion in AndroidManifest.xml).
 //
+// The range of platform versions can be overridden with minSDKVersion and maxSDKVersion,
+// and the APK is verified at each of verifySDKVersions one by one.
+//
 // - buildArtifactPth: The path of the signed APK
 func (configuration SignatureConfiguration) VerifyBuildArtifact(buildArtifactPth string) error {
 	cmdSlice := []string{
 		configuration.apkSigner,
 		"verify",
 		"--verbose",
+	}
+	cmdSlice = append(cmdSlice, createSDKVersionsCmdSlice(configuration.minSDKVersion, configuration.maxSDKVersion)...)
+	cmdSlice = append(cmdSlice, "--in", buildArtifactPth)
+
+	prinatableCmd := command.PrintableCommandArgs(false, cmdSlice)
+	log.Printf("=> %s", prinatableCmd)
+
+	out, err := executeForOutput(cmdSlice)
+	if err != nil {
+		return properError(err, out)
+	}
+
+	if len(configuration.verifySDKVersions) > 0 {
+		if err := configuration.verifyAtSDKVersions(buildArtifactPth); err != nil {
+			return err
+		}
+	}
+
+	if configuration.rotationConfiguration != nil {
+		if err := configuration.verifyRotatedSigner(buildArtifactPth); err != nil {
+			return err
+		}
+	}
+
+	if configuration.sourceStampConfiguration != nil {
+		return configuration.verifySourceStamp(buildArtifactPth)
+	}
+
+	return nil
+}
+
+// verifyAtSDKVersions verifies the APK at each API level of verifySDKVersions, and reports the schemes it verifies with.
+func (configuration SignatureConfiguration) verifyAtSDKVersions(buildArtifactPth string) error {
+	var failures []string
+	for _, sdkVersion := range configuration.verifySDKVersions {
+		cmdSlice := []string{configuration.apkSigner, "verify", "--verbose"}
+		cmdSlice = append(cmdSlice, createSDKVersionsCmdSlice(sdkVersion, sdkVersion)...)
+		cmdSlice = append(cmdSlice, "--in", buildArtifactPth)
+
+		prinatableCmd := command.PrintableCommandArgs(false, cmdSlice)
+		log.Printf("=> %s", prinatableCmd)
+
+		out, err := executeForOutput(cmdSlice)
+		if err != nil {
+			log.Errorf("API level %d: does not verify", sdkVersion)
+			failures = append(failures, fmt.Sprintf("API level %d: %s", sdkVersion, properError(err, out)))
+			continue
+		}
+
+		if schemes := parseVerifiedSchemes(out); len(schemes) > 0 {
+			log.Donef("API level %d: verifies with the %s scheme", sdkVersion, strings.Join(schemes, ", "))
+		} else {
+			log.Donef("API level %d: verifies", sdkVersion)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("does not verify at %d of %d API levels:\n%s", len(failures), len(configuration.verifySDKVersions), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+var verifiedSchemePattern = regexp.MustCompile(`(?m)^Verified using (v[0-9.]+) scheme.*:\s*true\s*$`)
+
+// parseVerifiedSchemes returns the signature schemes apksigner verify --verbose reports the APK verified with, in order.
+func parseVerifiedSchemes(out string) []string {
+	var schemes []string
+	for _, match := range verifiedSchemePattern.FindAllStringSubmatch(out, -1) {
+		schemes = append(schemes, match[1])
+	}
+	return schemes
+}
+
+// rotationVerificationSDKVersion returns the API level the rotated signer is checked at: the rotation min SDK version
+// (apksigner's default if 0), raised to the signing min SDK version. ok is false if the rotated signer is not used
+// below the signing max SDK version.
+// With targetsDevRelease the rotated signer is also used on the development release of that level,
+// which apksigner verify can not select, so the release itself is checked.
+func (configuration SignatureConfiguration) rotationVerificationSDKVersion() (sdkVersion int, ok bool) {
+	sdkVersion = configuration.rotationConfiguration.minSDKVersion
+	if sdkVersion == 0 {
+		sdkVersion = defaultRotationMinSDKVersion
+	}
+	if configuration.minSDKVersion > sdkVersion {
+		sdkVersion = configuration.minSDKVersion
+	}
+	if configuration.maxSDKVersion > 0 && configuration.maxSDKVersion < sdkVersion {
+		return sdkVersion, false
+	}
+	return sdkVersion, true
+}
+
+// verifyRotatedSigner checks that the rotated signer is among the signers reported for the rotation target SDK levels.
+func (configuration SignatureConfiguration) verifyRotatedSigner(buildArtifactPth string) error {
+	rotation := configuration.rotationConfiguration
+	sdkVersion, ok := configuration.rotationVerificationSDKVersion()
+	if !ok {
+		log.Warnf("The rotated signer is used from SDK level %d, above the max SDK version (%d), the APK is signed with the old signer only", sdkVersion, configuration.maxSDKVersion)
+		return nil
+	}
+
+	cmdSlice := []string{
+		configuration.apkSigner,
+		"verify",
+		"--print-certs",
+	}
+	cmdSlice = append(cmdSlice, createSDKVersionsCmdSlice(sdkVersion, configuration.maxSDKVersion)...)
+	cmdSlice = append(cmdSlice, "--in", buildArtifactPth)
+
+	prinatableCmd := command.PrintableCommandArgs(false, cmdSlice)
+	log.Printf("=> %s", prinatableCmd)
+
+	out, err := executeForOutput(cmdSlice)
+	if err != nil {
+		return properError(err, out)
+	}
+
+	if err := checkRotatedSigner(parseSignerCertificateDigests(out), rotation.rotatedCertificateSHA256, sdkVersion); err != nil {
+		return err
+	}
+	if rotation.targetsDevRelease {
+		log.Donef("The rotated signer is reported from SDK level %d, and targets its development release", sdkVersion)
+	} else {
+		log.Donef("The rotated signer is reported from SDK level %d", sdkVersion)
+	}
+	return nil
+}
+
+// checkRotatedSigner checks that the rotated certificate digest is among the signer digests, in any order.
+func checkRotatedSigner(digests []string, rotatedCertificateSHA256 string, sdkVersion int) error {
+	if len(digests) == 0 {
+		return fmt.Errorf("no signer reported for SDK level %d", sdkVersion)
+	}
+	for _, digest := range digests {
+		if digest == rotatedCertificateSHA256 {
+			return nil
+		}
+	}
+	return fmt.Errorf("none of the signers reported for SDK level %d (SHA-256 digests: %s) is the rotated signer (SHA-256 digest: %s)", sdkVersion, strings.Join(digests, ", "), rotatedCertificateSHA256)
+}
+
+// verifySourceStamp checks that the APK carries the SourceStamp of the stamp signer.
+func (configuration SignatureConfiguration) verifySourceStamp(buildArtifactPth string) error {
+	stamp := configuration.sourceStampConfiguration
+
+	cmdSlice := []string{
+		configuration.apkSigner,
+		"verify",
+		"--print-certs",
+		"--verbose",
 		"--in",
 		buildArtifactPth,
 	}
@@ -131,9 +338,39 @@ func (configuration SignatureConfiguration) VerifyBuildArtifact(buildArtifactPth
 		return properError(err, out)
 	}
 
+	digest := parseSourceStampCertificateDigest(out)
+	if digest == "" {
+		return fmt.Errorf("no SourceStamp found, expected the stamp of the stamp signer (SHA-256 digest: %s)", stamp.certificateSHA256)
+	}
+	if digest != stamp.certificateSHA256 {
+		return fmt.Errorf("SourceStamp signer (SHA-256 digest: %s) is not the stamp signer (SHA-256 digest: %s)", digest, stamp.certificateSHA256)
+	}
+	log.Donef("SourceStamp certificate SHA-256 digest: %s", digest)
 	return nil
 }
 
+var sourceStampCertificateDigestPattern = regexp.MustCompile(`(?m)^Source Stamp Signer certificate SHA-256 digest: ([0-9a-fA-F]+)\s*$`)
+
+// parseSourceStampCertificateDigest returns the SHA-256 certificate digest of the SourceStamp printed by apksigner verify --print-certs, empty if the APK is not stamped.
+func parseSourceStampCertificateDigest(out string) string {
+	match := sourceStampCertificateDigestPattern.FindStringSubmatch(out)
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+var signerCertificateDigestPattern = regexp.MustCompile(`(?m)^Signer.* certificate SHA-256 digest: ([0-9a-fA-F]+)\s*$`)
+
+// parseSignerCertificateDigests returns the SHA-256 certificate digests of the signers printed by apksigner verify --print-certs, in order.
+func parseSignerCertificateDigests(out string) []string {
+	var digests []string
+	for _, match := range signerCertificateDigestPattern.FindAllStringSubmatch(out, -1) {
+		digests = append(digests, strings.ToLower(match[1]))
+	}
+	return digests
+}
+
 func executeForOutput(cmdSlice []string) (string, error) {
 	cmd, err := command.NewFromSlice(cmdSlice)
 	if err != nil {
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateKeystoreCmdSlice(t *testing.T) {
+	t.Log("keystore type is passed as --ks-type")
+	{
+		cmdSlice, err := createKeystoreCmdSlice(&KeystoreSignatureConfiguration{
+			keystorePth:      "keystore.p12",
+			keystorePassword: "pass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "alias",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "--ks keystore.p12 --ks-pass pass:pass --ks-key-alias alias --ks-type PKCS12", strings.Join(cmdSlice, " "))
+	}
+
+	t.Log("unknown keystore type is omitted")
+	{
+		cmdSlice, err := createKeystoreCmdSlice(&KeystoreSignatureConfiguration{
+			keystorePth:      "keystore.jks",
+			keystorePassword: "pass",
+			alias:            "alias",
+			aliasPassword:    "keypass",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "--ks keystore.jks --ks-pass pass:pass --ks-key-alias alias --key-pass pass:keypass", strings.Join(cmdSlice, " "))
+	}
+
+	t.Log("key password is not passed for PKCS12 keystores")
+	{
+		cmdSlice, err := createKeystoreCmdSlice(&KeystoreSignatureConfiguration{
+			keystorePth:      "keystore.p12",
+			keystorePassword: "pass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "alias",
+			aliasPassword:    "keypass",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "--ks keystore.p12 --ks-pass pass:pass --ks-key-alias alias --ks-type PKCS12", strings.Join(cmdSlice, " "))
+	}
+
+	t.Log("BKS keystores are opened with the Bouncy Castle provider")
+	{
+		cmdSlice, err := createKeystoreCmdSlice(&KeystoreSignatureConfiguration{
+			keystorePth:      "keystore.bks",
+			keystorePassword: "pass",
+			keystoreType:     keystore.TypeBKS,
+			alias:            "alias",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "--ks keystore.bks --ks-pass pass:pass --ks-key-alias alias --ks-type BKS --provider-class org.bouncycastle.jce.provider.BouncyCastleProvider", strings.Join(cmdSlice, " "))
+	}
+}
+
+func TestCreateSignCmd(t *testing.T) {
+	t.Log("key and certificate are passed as --key and --cert")
+	{
+		cmdSlice, err := SignatureConfiguration{
+			apkSigner:           "apksigner",
+			debuggablePermitted: "true",
+			signers: []SignerConfiguration{{
+				signatureType: KeyCertificateSignatureType,
+				keyCertificateConfiguration: &KeyCertificateSignatureConfiguration{
+					keyPth:         "private-key.pk8",
+					certificatePth: "certificate.pem",
+				},
+			}},
+		}.createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted true --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --key private-key.pk8 --cert certificate.pem", strings.Join(cmdSlice, " "))
+	}
+
+	t.Log("missing configuration of the signature type")
+	{
+		_, err := SignatureConfiguration{signers: []SignerConfiguration{{signatureType: KeyCertificateSignatureType}}}.createSignCmd("app.apk", "app-signed.apk")
+		require.Error(t, err)
+	}
+
+	t.Log("no signer")
+	{
+		_, err := SignatureConfiguration{apkSigner: "apksigner"}.createSignCmd("app.apk", "app-signed.apk")
+		require.Error(t, err)
+	}
+}
+
+func TestCreateSignCmdWithMultipleSigners(t *testing.T) {
+	configuration := SignatureConfiguration{
+		apkSigner:           "apksigner",
+		debuggablePermitted: "false",
+		signers: []SignerConfiguration{{
+			signatureType: KeyCertificateSignatureType,
+			keyCertificateConfiguration: &KeyCertificateSignatureConfiguration{
+				keyPth:         "private-key.pk8",
+				certificatePth: "certificate.pem",
+			},
+		}},
+	}
+
+	t.Log("signers are rendered in order, each with its own secrets redacted")
+	{
+		signed := configuration.WithSigner(NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "partner.jks",
+			keystorePassword: "partnerpass",
+			keystoreType:     keystore.TypeJKS,
+			alias:            "partner",
+			aliasPassword:    "partnerkeypass",
+		})).WithSigner(NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "store.p12",
+			keystorePassword: "storepass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "store",
+		}))
+
+		cmdSlice, err := signed.createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --key private-key.pk8 --cert certificate.pem --next-signer --ks partner.jks --ks-pass pass:partnerpass --ks-key-alias partner --ks-type JKS --key-pass pass:partnerkeypass --next-signer --ks store.p12 --ks-pass pass:storepass --ks-key-alias store --ks-type PKCS12", strings.Join(cmdSlice, " "))
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --key private-key.pk8 --cert certificate.pem --next-signer --ks partner.jks --ks-pass *** --ks-key-alias partner --ks-type JKS --key-pass *** --next-signer --ks store.p12 --ks-pass *** --ks-key-alias store --ks-type PKCS12", strings.Join(secureSignCmd(cmdSlice), " "))
+	}
+
+	t.Log("adding a signer does not change the original configuration")
+	{
+		configuration.WithSigner(NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{keystorePth: "partner.jks"}))
+		require.Equal(t, 1, len(configuration.signers))
+	}
+
+	t.Log("invalid signer")
+	{
+		_, err := configuration.WithSigner(SignerConfiguration{signatureType: KeystoreSignatureType}).createSignCmd("app.apk", "app-signed.apk")
+		require.EqualError(t, err, "signer 2: Invalid Keystore Configuration")
+	}
+}
+
+func TestCreateSignCmdWithKeyRotation(t *testing.T) {
+	configuration := SignatureConfiguration{
+		apkSigner:           "apksigner",
+		debuggablePermitted: "false",
+		signers: []SignerConfiguration{NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "old.jks",
+			keystorePassword: "oldpass",
+			keystoreType:     keystore.TypeJKS,
+			alias:            "old",
+		})},
+	}
+
+	t.Log("the new signer follows the old signer")
+	{
+		cmdSlice, err := configuration.WithKeyRotation(KeystoreSignatureConfiguration{
+			keystorePth:      "new.p12",
+			keystorePassword: "newpass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "new",
+		}, NewRotationConfiguration("lineage", 0, false, "")).createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --lineage lineage --ks old.jks --ks-pass pass:oldpass --ks-key-alias old --ks-type JKS --next-signer --ks new.p12 --ks-pass pass:newpass --ks-key-alias new --ks-type PKCS12", strings.Join(cmdSlice, " "))
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --lineage lineage --ks old.jks --ks-pass *** --ks-key-alias old --ks-type JKS --next-signer --ks new.p12 --ks-pass *** --ks-key-alias new --ks-type PKCS12", strings.Join(secureSignCmd(cmdSlice), " "))
+	}
+
+	t.Log("rotation parameters")
+	{
+		cmdSlice, err := configuration.WithKeyRotation(KeystoreSignatureConfiguration{
+			keystorePth:      "new.jks",
+			keystorePassword: "newpass",
+			alias:            "new",
+		}, NewRotationConfiguration("lineage", 28, true, "")).createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --lineage lineage --rotation-min-sdk-version 28 --rotation-targets-dev-release --ks old.jks --ks-pass pass:oldpass --ks-key-alias old --ks-type JKS --next-signer --ks new.jks --ks-pass pass:newpass --ks-key-alias new", strings.Join(cmdSlice, " "))
+	}
+}
+
+func TestRotationVerificationSDKVersion(t *testing.T) {
+	tests := []struct {
+		name              string
+		rotationMinSDK    int
+		minSDKVersion     int
+		maxSDKVersion     int
+		wantSDKVersion    int
+		wantRotatedSigner bool
+	}{
+		{name: "apksigner default", wantSDKVersion: 33, wantRotatedSigner: true},
+		{name: "rotation min SDK version", rotationMinSDK: 28, wantSDKVersion: 28, wantRotatedSigner: true},
+		{name: "raised to the min SDK version", rotationMinSDK: 28, minSDKVersion: 30, wantSDKVersion: 30, wantRotatedSigner: true},
+		{name: "within the max SDK version", rotationMinSDK: 28, maxSDKVersion: 28, wantSDKVersion: 28, wantRotatedSigner: true},
+		{name: "above the max SDK version", maxSDKVersion: 32, wantSDKVersion: 33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := SignatureConfiguration{}.WithKeyRotation(KeystoreSignatureConfiguration{}, NewRotationConfiguration("lineage", tt.rotationMinSDK, false, "")).
+				WithSDKVersions(tt.minSDKVersion, tt.maxSDKVersion, nil)
+			sdkVersion, ok := configuration.rotationVerificationSDKVersion()
+			require.Equal(t, tt.wantSDKVersion, sdkVersion)
+			require.Equal(t, tt.wantRotatedSigner, ok)
+		})
+	}
+}
+
+func TestCheckRotatedSigner(t *testing.T) {
+	t.Log("finds the rotated signer regardless of its position")
+	{
+		require.NoError(t, checkRotatedSigner([]string{"4a0b7c2d", "9f8e7d6c"}, "9f8e7d6c", 33))
+		require.NoError(t, checkRotatedSigner([]string{"9f8e7d6c", "4a0b7c2d"}, "9f8e7d6c", 33))
+	}
+
+	t.Log("fails if the rotated signer is not reported")
+	{
+		require.EqualError(t, checkRotatedSigner([]string{"4a0b7c2d"}, "9f8e7d6c", 33), "none of the signers reported for SDK level 33 (SHA-256 digests: 4a0b7c2d) is the rotated signer (SHA-256 digest: 9f8e7d6c)")
+		require.EqualError(t, checkRotatedSigner(nil, "9f8e7d6c", 28), "no signer reported for SDK level 28")
+	}
+}
+
+func TestCreateSignCmdWithSourceStamp(t *testing.T) {
+	configuration := SignatureConfiguration{
+		apkSigner:           "apksigner",
+		debuggablePermitted: "false",
+		signers: []SignerConfiguration{NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "upload.jks",
+			keystorePassword: "uploadpass",
+			keystoreType:     keystore.TypeJKS,
+			alias:            "upload",
+		})},
+	}
+
+	t.Log("the stamp signer follows the signers")
+	{
+		cmdSlice, err := configuration.WithSourceStamp(NewSourceStampConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "stamp.p12",
+			keystorePassword: "stamppass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "stamp",
+		}, "")).createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --ks upload.jks --ks-pass pass:uploadpass --ks-key-alias upload --ks-type JKS --stamp-signer --ks stamp.p12 --ks-pass pass:stamppass --ks-key-alias stamp --ks-type PKCS12", strings.Join(cmdSlice, " "))
+		require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --ks upload.jks --ks-pass *** --ks-key-alias upload --ks-type JKS --stamp-signer --ks stamp.p12 --ks-pass *** --ks-key-alias stamp --ks-type PKCS12", strings.Join(secureSignCmd(cmdSlice), " "))
+	}
+
+	t.Log("no stamp signer by default")
+	{
+		cmdSlice, err := configuration.createSignCmd("app.apk", "app-signed.apk")
+		require.NoError(t, err)
+		require.NotContains(t, cmdSlice, "--stamp-signer")
+	}
+}
+
+func TestCreateSignCmdWithSDKVersions(t *testing.T) {
+	cmdSlice, err := SignatureConfiguration{
+		apkSigner:           "apksigner",
+		signerSchemes:       SignerSchemes{1: false},
+		debuggablePermitted: "false",
+		signers: []SignerConfiguration{NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "upload.jks",
+			keystorePassword: "uploadpass",
+			alias:            "upload",
+		})},
+	}.WithSDKVersions(24, 33, []int{24, 33}).createSignCmd("app.apk", "app-signed.apk")
+	require.NoError(t, err)
+	require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled false --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --min-sdk-version 24 --max-sdk-version 33 --ks upload.jks --ks-pass pass:uploadpass --ks-key-alias upload", strings.Join(cmdSlice, " "))
+}
+
+func TestCreateSignCmdWithAPKMinSDKVersion(t *testing.T) {
+	cmdSlice, err := SignatureConfiguration{
+		apkSigner:           "apksigner",
+		signerSchemes:       SignerSchemes{2: true},
+		debuggablePermitted: "false",
+		signers: []SignerConfiguration{NewKeystoreSignerConfiguration(KeystoreSignatureConfiguration{
+			keystorePth:      "upload.jks",
+			keystorePassword: "uploadpass",
+			alias:            "upload",
+		})},
+	}.WithAPKMinSDKVersion(26).createSignCmd("app.apk", "app-signed.apk")
+	require.NoError(t, err)
+	require.Equal(t, "apksigner sign --in app.apk --out app-signed.apk --debuggable-apk-permitted false --v1-signing-enabled false --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true --ks upload.jks --ks-pass pass:uploadpass --ks-key-alias upload", strings.Join(cmdSlice, " "))
+}
+
+func TestParseVerifiedSchemes(t *testing.T) {
+	out := `Verifies
+Verified using v1 scheme (JAR signing): false
+Verified using v2 scheme (APK Signature Scheme v2): true
+Verified using v3 scheme (APK Signature Scheme v3): true
+Verified using v3.1 scheme (APK Signature Scheme v3.1): false
+Verified using v4 scheme (APK Signature Scheme v4): false
+Verified for SourceStamp: false
+Number of signers: 1
+`
+	require.Equal(t, []string{"v2", "v3"}, parseVerifiedSchemes(out))
+	require.Empty(t, parseVerifiedSchemes("Verifies\n"))
+}
+
+func TestParseSourceStampCertificateDigest(t *testing.T) {
+	out := `Verifies
+Verified using v2 scheme (APK Signature Scheme v2): true
+Signer #1 certificate DN: CN=Upload Key
+Signer #1 certificate SHA-256 digest: 4a0b7c2d
+Source Stamp Signer certificate DN: CN=Stamp Key
+Source Stamp Signer certificate SHA-256 digest: 9F8E7D6C
+Source Stamp Signer certificate SHA-1 digest: 0123
+`
+	require.Equal(t, "9f8e7d6c", parseSourceStampCertificateDigest(out))
+	require.Equal(t, []string{"4a0b7c2d"}, parseSignerCertificateDigests(out))
+	require.Empty(t, parseSourceStampCertificateDigest("Verifies\nSigner #1 certificate SHA-256 digest: 4a0b7c2d\n"))
+}
+
+func TestParseSignerCertificateDigests(t *testing.T) {
+	out := `Signer #1 certificate DN: CN=New Key
+Signer #1 certificate SHA-256 digest: 4A0B7C2D
+Signer #1 certificate SHA-1 digest: 0123
+Signer (minSdkVersion=24, maxSdkVersion=32) certificate DN: CN=Old Key
+Signer (minSdkVersion=24, maxSdkVersion=32) certificate SHA-256 digest: 9f8e7d6c
+`
+	require.Equal(t, []string{"4a0b7c2d", "9f8e7d6c"}, parseSignerCertificateDigests(out))
+	require.Empty(t, parseSignerCertificateDigests("Verifies\n"))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/bitrise-io/go-android/sdk"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
 )
 
 // SignatureType ..
@@ -12,24 +13,64 @@ type SignatureType string
 
 // SignatureType values
 const (
-	KeystoreSignatureType SignatureType = "keystore"
+	KeystoreSignatureType       SignatureType = "keystore"
+	KeyCertificateSignatureType SignatureType = "key_certificate"
 )
 
 // KeystoreSignatureConfiguration ..
 type KeystoreSignatureConfiguration struct {
 	keystorePth      string
 	keystorePassword string
+	keystoreType     keystore.Type
 	aliasPassword    string
 	alias            string
 }
 
+// KeyCertificateSignatureConfiguration is an unencrypted PKCS#8 DER private key and its X.509 certificate chain.
+type KeyCertificateSignatureConfiguration struct {
+	keyPth         string
+	certificatePth string
+}
+
+// RotationConfiguration is the signing certificate lineage and the parameters of APK Signature Scheme v3.1 key rotation.
+type RotationConfiguration struct {
+	lineagePth        string
+	minSDKVersion     int
+	targetsDevRelease bool
+	// rotatedCertificateSHA256 is the lowercase hex SHA-256 digest of the certificate of the new signer.
+	rotatedCertificateSHA256 string
+}
+
+// SourceStampConfiguration is the signer of the SourceStamp of APKs, attributing them to their source.
+type SourceStampConfiguration struct {
+	signer KeystoreSignatureConfiguration
+	// certificateSHA256 is the lowercase hex SHA-256 digest of the certificate of the stamp signer.
+	certificateSHA256 string
+}
+
+// SignerConfiguration is a signer of the build artifact, its key is either in a keystore or in a key file.
+type SignerConfiguration struct {
+	signatureType               SignatureType
+	keystoreConfiguration       *KeystoreSignatureConfiguration
+	keyCertificateConfiguration *KeyCertificateSignatureConfiguration
+}
+
 // SignatureConfiguration ...
 type SignatureConfiguration struct {
-	apkSigner             string
-	signerScheme          string
-	debuggablePermitted   string
-	signatureType         SignatureType
-	keystoreConfiguration *KeystoreSignatureConfiguration
+	apkSigner     string
+	signerSchemes SignerSchemes
+	// apkMinSDKVersion is the minSdkVersion the signer schemes which are not set are resolved for.
+	apkMinSDKVersion    int
+	debuggablePermitted string
+	// signers sign the build artifact in order, in a key rotation the last one is the new signer.
+	signers                  []SignerConfiguration
+	rotationConfiguration    *RotationConfiguration
+	sourceStampConfiguration *SourceStampConfiguration
+	// minSDKVersion and maxSDKVersion override the API level range of the manifest, if not 0.
+	minSDKVersion int
+	maxSDKVersion int
+	// verifySDKVersions are the API levels the signed APK is verified at one by one.
+	verifySDKVersions []int
 }
 
 func buildAPKSignerPath() (string, error) {
@@ -49,7 +90,7 @@ func buildAPKSignerPath() (string, error) {
 }
 
 // NewKeystoreSignatureConfiguration ...
-func NewKeystoreSignatureConfiguration(keystore string, keystorePassword string, alias string, aliasPassword string, debuggablePermitted string, signerScheme string) (SignatureConfiguration, error) {
+func NewKeystoreSignatureConfiguration(keystorePth string, keystorePassword string, keystoreType keystore.Type, alias string, aliasPassword string, debuggablePermitted string, signerSchemes SignerSchemes) (SignatureConfiguration, error) {
 	apkSigner, err := buildAPKSignerPath()
 
 	if err != nil {
@@ -57,17 +98,101 @@ func NewKeystoreSignatureConfiguration(keystore string, keystorePassword string,
 	}
 
 	keystoreConfig := KeystoreSignatureConfiguration{
-		keystorePth:      keystore,
+		keystorePth:      keystorePth,
 		keystorePassword: keystorePassword,
+		keystoreType:     keystoreType,
 		alias:            alias,
 		aliasPassword:    aliasPassword,
 	}
 
 	return SignatureConfiguration{
-		apkSigner:             apkSigner,
-		debuggablePermitted:   debuggablePermitted,
-		signerScheme:          signerScheme,
+		apkSigner:           apkSigner,
+		debuggablePermitted: debuggablePermitted,
+		signerSchemes:       signerSchemes,
+		signers:             []SignerConfiguration{NewKeystoreSignerConfiguration(keystoreConfig)},
+	}, nil
+}
+
+// NewKeyCertificateSignatureConfiguration ...
+func NewKeyCertificateSignatureConfiguration(keyPth string, certificatePth string, debuggablePermitted string, signerSchemes SignerSchemes) (SignatureConfiguration, error) {
+	apkSigner, err := buildAPKSignerPath()
+
+	if err != nil {
+		return SignatureConfiguration{}, err
+	}
+
+	return SignatureConfiguration{
+		apkSigner:           apkSigner,
+		debuggablePermitted: debuggablePermitted,
+		signerSchemes:       signerSchemes,
+		signers: []SignerConfiguration{{
+			signatureType: KeyCertificateSignatureType,
+			keyCertificateConfiguration: &KeyCertificateSignatureConfiguration{
+				keyPth:         keyPth,
+				certificatePth: certificatePth,
+			},
+		}},
+	}, nil
+}
+
+// NewKeystoreSignerConfiguration ...
+func NewKeystoreSignerConfiguration(keystoreConfig KeystoreSignatureConfiguration) SignerConfiguration {
+	return SignerConfiguration{
 		signatureType:         KeystoreSignatureType,
 		keystoreConfiguration: &keystoreConfig,
-	}, nil
+	}
+}
+
+// NewRotationConfiguration ...
+// A minSDKVersion of 0 keeps the default of apksigner (33).
+func NewRotationConfiguration(lineagePth string, minSDKVersion int, targetsDevRelease bool, rotatedCertificateSHA256 string) RotationConfiguration {
+	return RotationConfiguration{
+		lineagePth:               lineagePth,
+		minSDKVersion:            minSDKVersion,
+		targetsDevRelease:        targetsDevRelease,
+		rotatedCertificateSHA256: rotatedCertificateSHA256,
+	}
+}
+
+// NewSourceStampConfiguration ...
+func NewSourceStampConfiguration(signer KeystoreSignatureConfiguration, certificateSHA256 string) SourceStampConfiguration {
+	return SourceStampConfiguration{
+		signer:            signer,
+		certificateSHA256: certificateSHA256,
+	}
+}
+
+// WithKeyRotation returns the configuration rotating the signing key to the key of nextSigner.
+func (configuration SignatureConfiguration) WithKeyRotation(nextSigner KeystoreSignatureConfiguration, rotation RotationConfiguration) SignatureConfiguration {
+	configuration = configuration.WithSigner(NewKeystoreSignerConfiguration(nextSigner))
+	configuration.rotationConfiguration = &rotation
+	return configuration
+}
+
+// WithSigner returns the configuration signing the build artifact with signer too, after the signers of configuration.
+func (configuration SignatureConfiguration) WithSigner(signer SignerConfiguration) SignatureConfiguration {
+	signers := make([]SignerConfiguration, 0, len(configuration.signers)+1)
+	configuration.signers = append(append(signers, configuration.signers...), signer)
+	return configuration
+}
+
+// WithSourceStamp returns the configuration stamping APKs with the key of the stamp signer.
+func (configuration SignatureConfiguration) WithSourceStamp(stamp SourceStampConfiguration) SignatureConfiguration {
+	configuration.sourceStampConfiguration = &stamp
+	return configuration
+}
+
+// WithAPKMinSDKVersion returns the configuration resolving the signer schemes which are not set for an APK of minSDKVersion.
+func (configuration SignatureConfiguration) WithAPKMinSDKVersion(minSDKVersion int) SignatureConfiguration {
+	configuration.apkMinSDKVersion = minSDKVersion
+	return configuration
+}
+
+// WithSDKVersions returns the configuration signing and verifying for the API levels from minSDKVersion to maxSDKVersion,
+// and verifying at each of verifySDKVersions.
+func (configuration SignatureConfiguration) WithSDKVersions(minSDKVersion, maxSDKVersion int, verifySDKVersions []int) SignatureConfiguration {
+	configuration.minSDKVersion = minSDKVersion
+	configuration.maxSDKVersion = maxSDKVersion
+	configuration.verifySDKVersions = verifySDKVersions
+	return configuration
 }
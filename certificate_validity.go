@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+const (
+	validityCheckFail = "fail"
+	validityCheckWarn = "warn"
+	validityCheckOff  = "off"
+
+	// googlePlayValidUntilPreset is the certificate_valid_until preset of the Google Play upload key requirement.
+	googlePlayValidUntilPreset = "google_play"
+	validUntilLayout           = "2006-01-02"
+)
+
+// googlePlayValidUntil is the day until which Google Play requires the certificate of the signing key to be valid.
+var googlePlayValidUntil = time.Date(2033, time.October, 22, 0, 0, 0, 0, time.UTC)
+
+// certificateValidityPolicy is the signing certificate validity required by the certificate_validity_* inputs.
+type certificateValidityPolicy struct {
+	mode            string
+	minValidityDays int
+	// validUntil is the day (in UTC) until the end of which the certificate has to be valid, zero if not required.
+	validUntil time.Time
+}
+
+func parseCertificateValidityPolicy(mode string, minValidityDays int, validUntil string) (certificateValidityPolicy, error) {
+	switch mode {
+	case "":
+		mode = validityCheckWarn
+	case validityCheckFail, validityCheckWarn, validityCheckOff:
+	default:
+		return certificateValidityPolicy{}, fmt.Errorf("certificate_validity_check: invalid value (%s), available values: fail, warn, off", mode)
+	}
+
+	if minValidityDays < 0 {
+		return certificateValidityPolicy{}, fmt.Errorf("certificate_min_validity_days must not be negative")
+	}
+
+	policy := certificateValidityPolicy{mode: mode, minValidityDays: minValidityDays}
+	switch validUntil {
+	case "":
+	case googlePlayValidUntilPreset:
+		policy.validUntil = googlePlayValidUntil
+	default:
+		day, err := time.Parse(validUntilLayout, validUntil)
+		if err != nil {
+			return certificateValidityPolicy{}, fmt.Errorf("certificate_valid_until: invalid date (%s), use the YYYY-MM-DD format or %s", validUntil, googlePlayValidUntilPreset)
+		}
+		policy.validUntil = day
+	}
+	return policy, nil
+}
+
+// validityViolations returns the reasons why the certificate does not meet the policy at now.
+func (policy certificateValidityPolicy) validityViolations(certificate keystore.CertificateInfo, now time.Time) []string {
+	if policy.mode == validityCheckOff {
+		return nil
+	}
+
+	var violations []string
+	if now.Before(certificate.NotBefore) {
+		violations = append(violations, fmt.Sprintf("the certificate is not valid yet, it is valid from %s", certificate.NotBefore.UTC()))
+	}
+
+	if !now.Before(certificate.NotAfter) {
+		violations = append(violations, fmt.Sprintf("the certificate expired at %s", certificate.NotAfter.UTC()))
+	} else if policy.minValidityDays > 0 && certificate.NotAfter.Before(now.AddDate(0, 0, policy.minValidityDays)) {
+		violations = append(violations, fmt.Sprintf("the certificate expires at %s, within %d days", certificate.NotAfter.UTC(), policy.minValidityDays))
+	}
+
+	if !policy.validUntil.IsZero() && certificate.NotAfter.Before(policy.validUntil.AddDate(0, 0, 1)) {
+		violations = append(violations, fmt.Sprintf("the certificate expires at %s, it has to be valid after %s", certificate.NotAfter.UTC(), policy.validUntil.Format(validUntilLayout)))
+	}
+	return violations
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCertificateValidityPolicy(t *testing.T) {
+	t.Log("parses the presets and dates")
+	{
+		policy, err := parseCertificateValidityPolicy("", 0, "")
+		require.NoError(t, err)
+		require.Equal(t, certificateValidityPolicy{mode: validityCheckWarn}, policy)
+
+		policy, err = parseCertificateValidityPolicy("fail", 30, "google_play")
+		require.NoError(t, err)
+		require.Equal(t, certificateValidityPolicy{mode: validityCheckFail, minValidityDays: 30, validUntil: time.Date(2033, time.October, 22, 0, 0, 0, 0, time.UTC)}, policy)
+
+		policy, err = parseCertificateValidityPolicy("warn", 0, "2030-01-31")
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2030, time.January, 31, 0, 0, 0, 0, time.UTC), policy.validUntil)
+	}
+
+	t.Log("rejects invalid inputs")
+	{
+		_, err := parseCertificateValidityPolicy("error", 0, "")
+		require.Error(t, err)
+
+		_, err = parseCertificateValidityPolicy("fail", -1, "")
+		require.Error(t, err)
+
+		_, err = parseCertificateValidityPolicy("fail", 0, "22/10/2033")
+		require.Error(t, err)
+	}
+}
+
+func TestValidityViolations(t *testing.T) {
+	now := time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC)
+	certificate := func(notBefore, notAfter time.Time) keystore.CertificateInfo {
+		return keystore.CertificateInfo{NotBefore: notBefore, NotAfter: notAfter}
+	}
+	longLived := certificate(now.AddDate(-1, 0, 0), now.AddDate(25, 0, 0))
+
+	tests := []struct {
+		name        string
+		policy      certificateValidityPolicy
+		certificate keystore.CertificateInfo
+		want        int
+	}{
+		{"valid certificate", certificateValidityPolicy{mode: validityCheckFail, minValidityDays: 365, validUntil: googlePlayValidUntil}, longLived, 0},
+		{"not valid yet", certificateValidityPolicy{mode: validityCheckFail}, certificate(now.Add(time.Hour), now.AddDate(1, 0, 0)), 1},
+		{"expired", certificateValidityPolicy{mode: validityCheckFail, minValidityDays: 30}, certificate(now.AddDate(-1, 0, 0), now.Add(-time.Hour)), 1},
+		{"expires within the minimum validity", certificateValidityPolicy{mode: validityCheckWarn, minValidityDays: 30}, certificate(now.AddDate(-1, 0, 0), now.AddDate(0, 0, 29)), 1},
+		{"expires after the minimum validity", certificateValidityPolicy{mode: validityCheckWarn, minValidityDays: 30}, certificate(now.AddDate(-1, 0, 0), now.AddDate(0, 0, 31)), 0},
+		{"expires on the valid until day", certificateValidityPolicy{mode: validityCheckFail, validUntil: googlePlayValidUntil}, certificate(now, time.Date(2033, time.October, 22, 23, 0, 0, 0, time.UTC)), 1},
+		{"expires after the valid until day", certificateValidityPolicy{mode: validityCheckFail, validUntil: googlePlayValidUntil}, certificate(now, time.Date(2033, time.October, 23, 0, 0, 0, 0, time.UTC)), 0},
+		{"check is off", certificateValidityPolicy{mode: validityCheckOff, validUntil: googlePlayValidUntil}, certificate(now.Add(time.Hour), now.Add(2*time.Hour)), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := tt.policy.validityViolations(tt.certificate, now)
+			require.Len(t, violations, tt.want, violations)
+		})
+	}
+}
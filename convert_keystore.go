@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+const convertedKeystorePathEnvKey = "BITRISE_CONVERTED_KEYSTORE_PATH"
+
+// convertKeystore writes the JKS or JCEKS keystore as a PKCS#12 keystore to outputPath, protected with newPassword
+// (the keystore password if empty), and checks that the converted keystore holds the same private keys and certificate chains.
+// Existing files are never overwritten.
+func convertKeystore(source openedKeystore, outputPath, newPassword string) error {
+	if err := ensureNotExists(outputPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	credentials := source.credentials
+	if err := keystore.ConvertToPKCS12(source.path, source.keystoreType, credentials.keystorePassword, credentials.alias, credentials.keyPassword, outputPath, newPassword); err != nil {
+		return err
+	}
+
+	if err := keystore.VerifyConversion(source.path, source.keystoreType, credentials.keystorePassword, credentials.alias, credentials.keyPassword, outputPath, newPassword); err != nil {
+		return fmt.Errorf("failed to verify the converted keystore: %s", err)
+	}
+	return nil
+}
+
+func runConvertKeystore(cfg configs, ws *workspace, resolvers keystoreResolvers, vault *vaultClient) {
+	source, err := openKeystore(ws, resolvers, vault, signingCredentials{
+		name:             "keystore_url",
+		keystoreURL:      string(cfg.KeystoreURL),
+		keystorePassword: string(cfg.KeystorePassword),
+		alias:            string(cfg.KeystoreAlias),
+		keyPassword:      string(cfg.PrivateKeyPassword),
+		keystoreSHA256:   cfg.KeystoreSHA256,
+	}, keystoreFileName, cfg.KeystoreType)
+	failOnKeystoreError("Run", err)
+	if source.keystoreType != keystore.TypeJKS && source.keystoreType != keystore.TypeJCEKS {
+		failf("Run: only jks and jceks keystores can be converted, the keystore is: %s", source.keystoreType.StoreType())
+	}
+	newPassword, err := resolveVaultSecret(vault, "converted keystore password", string(cfg.ConvertedKeystorePassword))
+	if err != nil {
+		failf("Run: %s", err)
+	}
+
+	log.Infof("Convert %s keystore to PKCS12", source.keystoreType.StoreType())
+	if err := convertKeystore(source, cfg.ConvertedKeystorePath, newPassword); err != nil {
+		failf("Run: failed to convert keystore: %s", err)
+	}
+	log.Donef("The converted keystore holds the same private keys and certificate chains")
+	exportCertificateInfo(source.helper.CertificateInfo())
+
+	if err := tools.ExportEnvironmentWithEnvman(convertedKeystorePathEnvKey, cfg.ConvertedKeystorePath); err != nil {
+		log.Warnf("Failed to export path (%s), error: %s", cfg.ConvertedKeystorePath, err)
+	} else {
+		log.Donef("The path is now available in the Environment Variable: %s (value: %s)", convertedKeystorePathEnvKey, cfg.ConvertedKeystorePath)
+	}
+	if newPassword == "" {
+		log.Printf("The converted keystore is protected with the keystore password.")
+	}
+}
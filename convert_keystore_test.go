@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertKeystore(t *testing.T) {
+	if _, err := exec.LookPath("keytool"); err != nil {
+		t.Skip("keytool is not installed")
+	}
+
+	dir := t.TempDir()
+	jksPath, _, err := generateKeystore(dir, keystore.TypeJKS, keystore.GenerateOptions{
+		Alias:             "upload",
+		DistinguishedName: "CN=Upload Key",
+		KeySpec:           keystore.KeySpecECP256,
+		ValidityDays:      10000,
+	}, "storepass", "keypass")
+	require.NoError(t, err)
+
+	helper, err := keystore.NewHelper(jksPath, "storepass", "upload", "keypass", keystore.TypeJKS)
+	require.NoError(t, err)
+	source := openedKeystore{
+		credentials:  signingCredentials{keystorePassword: "storepass", alias: "upload", keyPassword: "keypass"},
+		path:         jksPath,
+		keystoreType: keystore.TypeJKS,
+		helper:       helper,
+	}
+
+	t.Log("converts the keystore with a new password")
+	{
+		outputPath := filepath.Join(dir, "converted", "keystore.p12")
+		require.NoError(t, convertKeystore(source, outputPath, "newpass"))
+
+		converted, err := keystore.NewHelper(outputPath, "newpass", "upload", "", keystore.TypePKCS12)
+		require.NoError(t, err)
+		require.Equal(t, helper.CertificateInfo().SHA256, converted.CertificateInfo().SHA256)
+
+		t.Log("does not overwrite existing files")
+		require.Error(t, convertKeystore(source, outputPath, "newpass"))
+	}
+
+	t.Log("keeps the keystore password if the new password is empty")
+	{
+		outputPath := filepath.Join(dir, "same-password.p12")
+		require.NoError(t, convertKeystore(source, outputPath, ""))
+
+		_, err := keystore.NewHelper(outputPath, "storepass", "upload", "", keystore.TypePKCS12)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+const credentialSetEnvKey = "BITRISE_SIGNING_CREDENTIAL_SET"
+
+// signingCredentials is a keystore with the credentials of the signing key inside it.
+type signingCredentials struct {
+	// name identifies the set in logs and errors, it never contains secret values.
+	name string
+
+	keystoreURL      string
+	keystorePassword string
+	alias            string
+	keyPassword      string
+	keystoreSHA256   string
+	// keystoreType is the keystore_type of the set (automatic, jks, pkcs12 or jceks), empty if the set does not define it.
+	keystoreType string
+}
+
+// openedKeystore is a keystore which could be opened with its signingCredentials.
+type openedKeystore struct {
+	credentials  signingCredentials
+	path         string
+	keystoreType keystore.Type
+	helper       keystore.Helper
+}
+
+// keystoreIntegrityError is not recovered by falling back to the next credential set.
+type keystoreIntegrityError struct {
+	err error
+}
+
+func (e keystoreIntegrityError) Error() string {
+	return e.err.Error()
+}
+
+// parseFallbackCredentials parses the fallback_credentials input.
+func parseFallbackCredentials(list string) ([]signingCredentials, error) {
+	return parseCredentialSets(list, "fallback")
+}
+
+// parseCredentialSets parses a list of credential sets, named after kind and their 1-based index.
+// Each line holds the names of the environment variables of a credential set, optionally followed by the keystore type:
+// KEYSTORE_URL_VAR, KEYSTORE_PASSWORD_VAR, ALIAS_VAR[, KEY_PASSWORD_VAR[, KEYSTORE_TYPE]]
+func parseCredentialSets(list, kind string) ([]signingCredentials, error) {
+	var sets []signingCredentials
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		envKeys := strings.Split(line, ",")
+		for i := range envKeys {
+			envKeys[i] = strings.TrimSpace(envKeys[i])
+		}
+		if len(envKeys) < 3 || len(envKeys) > 5 || envKeys[0] == "" || envKeys[1] == "" || envKeys[2] == "" {
+			return nil, fmt.Errorf("invalid credential set (%s), expected format: KEYSTORE_URL_VAR, KEYSTORE_PASSWORD_VAR, ALIAS_VAR[, KEY_PASSWORD_VAR[, KEYSTORE_TYPE]]", line)
+		}
+
+		var keystoreType string
+		if len(envKeys) == 5 {
+			keystoreType = envKeys[4]
+			envKeys = envKeys[:4]
+			if !isKeystoreTypeInput(keystoreType) {
+				return nil, fmt.Errorf("invalid credential set (%s), unknown keystore type (%s), expected one of: %s", line, keystoreType, strings.Join(keystoreTypeInputs, ", "))
+			}
+		}
+		for _, envKey := range envKeys {
+			if strings.HasPrefix(envKey, "$") {
+				return nil, fmt.Errorf("invalid credential set (%s), use the names of the environment variables without the $ sign", line)
+			}
+		}
+
+		set := signingCredentials{
+			name:             fmt.Sprintf("%s %d (%s)", kind, len(sets)+1, envKeys[0]),
+			keystoreURL:      os.Getenv(envKeys[0]),
+			keystorePassword: os.Getenv(envKeys[1]),
+			alias:            os.Getenv(envKeys[2]),
+			keystoreType:     keystoreType,
+		}
+		if len(envKeys) == 4 && envKeys[3] != "" {
+			set.keyPassword = os.Getenv(envKeys[3])
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// keystoreTypeInputs are the values of the keystore_type inputs.
+var keystoreTypeInputs = []string{"automatic", "jks", "pkcs12", "jceks", "bks"}
+
+func isKeystoreTypeInput(value string) bool {
+	for _, typeInput := range keystoreTypeInputs {
+		if value == typeInput {
+			return true
+		}
+	}
+	return false
+}
+
+// typeInput returns the keystore type of the set, or defaultType if the set does not define it.
+func (set signingCredentials) typeInput(defaultType string) string {
+	if set.keystoreType != "" {
+		return set.keystoreType
+	}
+	return defaultType
+}
+
+// selectCredentials returns the index of the first credential set which opens, and the opened keystore.
+func selectCredentials(sets []signingCredentials, open func(i int, set signingCredentials) (openedKeystore, error)) (int, openedKeystore, error) {
+	var failures []string
+	for i, set := range sets {
+		if len(sets) > 1 {
+			log.Infof("Open keystore of credential set %d/%d: %s", i+1, len(sets), set.name)
+		}
+
+		opened, err := open(i, set)
+		if err == nil {
+			return i, opened, nil
+		}
+
+		var integrityErr keystoreIntegrityError
+		if errors.As(err, &integrityErr) {
+			return i, openedKeystore{}, err
+		}
+
+		if len(sets) == 1 {
+			return i, openedKeystore{}, err
+		}
+		log.Warnf("Failed to open keystore of credential set %s: %s", set.name, err)
+		failures = append(failures, fmt.Sprintf("%s: %s", set.name, err))
+	}
+	return -1, openedKeystore{}, fmt.Errorf("none of the credential sets could be opened:\n%s", strings.Join(failures, "\n"))
+}
+
+// openKeystore makes the keystore of the credential set available at a local path and opens it.
+func openKeystore(ws *workspace, resolvers keystoreResolvers, vault *vaultClient, set signingCredentials, fileName, typeInput string) (openedKeystore, error) {
+	var err error
+	for _, secret := range []struct {
+		name  string
+		value *string
+	}{
+		{"keystore password", &set.keystorePassword},
+		{"alias", &set.alias},
+		{"key password", &set.keyPassword},
+	} {
+		if *secret.value, err = resolveVaultSecret(vault, secret.name, *secret.value); err != nil {
+			return openedKeystore{}, err
+		}
+	}
+
+	keystorePath, isTemporaryKeystore, err := resolvers.resolve(set.keystoreURL, fileName, ws)
+	if err != nil {
+		return openedKeystore{}, err
+	}
+
+	if err := checkKeystoreIntegrity(keystorePath, set.keystoreSHA256); err != nil {
+		return openedKeystore{}, keystoreIntegrityError{err: err}
+	}
+
+	keystoreType, err := resolveKeystoreType(keystorePath, typeInput)
+	if err != nil {
+		return openedKeystore{}, fmt.Errorf("invalid keystore: %s", err)
+	}
+
+	if isTemporaryKeystore && keystoreType != keystore.TypeUnknown {
+		typedKeystorePath := keystorePath + keystoreType.Extension()
+		ws.trackSecret(typedKeystorePath)
+		if err := os.Rename(keystorePath, typedKeystorePath); err != nil {
+			return openedKeystore{}, fmt.Errorf("failed to rename keystore: %s", err)
+		}
+		keystorePath = typedKeystorePath
+	}
+	log.Printf("using keystore at: %s", keystorePath)
+
+	keyPassword, ignored := keystore.SignerKeyPassword(keystoreType, set.keystorePassword, set.keyPassword)
+	if ignored {
+		log.Warnf("The key password of credential set %s differs from the keystore password, but keys of PKCS12 keystores are protected with the keystore password.", set.name)
+		log.Warnf("The key password is ignored, jarsigner and apksigner use the keystore password. Clear the key password input to silence this warning.")
+	}
+	set.keyPassword = keyPassword
+
+	helper, err := keystore.NewHelper(keystorePath, set.keystorePassword, set.alias, set.keyPassword, keystoreType)
+	if err != nil {
+		return openedKeystore{}, fmt.Errorf("failed to open keystore: %w", err)
+	}
+
+	set.alias = helper.Alias()
+
+	return openedKeystore{
+		credentials:  set,
+		path:         keystorePath,
+		keystoreType: keystoreType,
+		helper:       helper,
+	}, nil
+}
+
+// credentialSetFileName returns the workspace file name of the keystore of the i-th credential set.
+func credentialSetFileName(i int) string {
+	if i == 0 {
+		return keystoreFileName
+	}
+	return fmt.Sprintf("%s-%d", keystoreFileName, i)
+}
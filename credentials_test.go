@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFallbackCredentials(t *testing.T) {
+	t.Setenv("OLD_KEYSTORE_URL", "file://old.jks")
+	t.Setenv("OLD_KEYSTORE_PASSWORD", "store pass")
+	t.Setenv("OLD_KEYSTORE_ALIAS", "upload")
+	t.Setenv("OLD_KEY_PASSWORD", "key pass")
+
+	t.Log("parses credential sets with and without key password")
+	{
+		sets, err := parseFallbackCredentials(`
+OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, OLD_KEY_PASSWORD
+OLD_KEYSTORE_URL,OLD_KEYSTORE_PASSWORD,OLD_KEYSTORE_ALIAS
+`)
+		require.NoError(t, err)
+		require.Equal(t, []signingCredentials{
+			{
+				name:             "fallback 1 (OLD_KEYSTORE_URL)",
+				keystoreURL:      "file://old.jks",
+				keystorePassword: "store pass",
+				alias:            "upload",
+				keyPassword:      "key pass",
+			},
+			{
+				name:             "fallback 2 (OLD_KEYSTORE_URL)",
+				keystoreURL:      "file://old.jks",
+				keystorePassword: "store pass",
+				alias:            "upload",
+			},
+		}, sets)
+	}
+
+	t.Log("parses the keystore type of the sets")
+	{
+		sets, err := parseFallbackCredentials(`
+OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, OLD_KEY_PASSWORD, jceks
+OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, , pkcs12
+`)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(sets))
+		require.Equal(t, "jceks", sets[0].keystoreType)
+		require.Equal(t, "key pass", sets[0].keyPassword)
+		require.Equal(t, "pkcs12", sets[1].keystoreType)
+		require.Equal(t, "", sets[1].keyPassword)
+
+		require.Equal(t, "jceks", sets[0].typeInput("automatic"))
+		require.Equal(t, "jks", signingCredentials{}.typeInput("jks"))
+	}
+
+	t.Log("rejects invalid sets")
+	{
+		for _, list := range []string{
+			"OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD",
+			"OLD_KEYSTORE_URL, , OLD_KEYSTORE_ALIAS",
+			"OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, OLD_KEY_PASSWORD, EXTRA",
+			"OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, OLD_KEY_PASSWORD, p12",
+			"OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS, OLD_KEY_PASSWORD, jks, EXTRA",
+			"$OLD_KEYSTORE_URL, OLD_KEYSTORE_PASSWORD, OLD_KEYSTORE_ALIAS",
+		} {
+			_, err := parseFallbackCredentials(list)
+			require.Error(t, err, list)
+		}
+	}
+}
+
+func TestSelectCredentials(t *testing.T) {
+	sets := []signingCredentials{{name: "new"}, {name: "old"}, {name: "oldest"}}
+
+	t.Log("selects the first set which opens")
+	{
+		var tried []string
+		i, opened, err := selectCredentials(sets, func(i int, set signingCredentials) (openedKeystore, error) {
+			tried = append(tried, set.name)
+			if set.name == "new" {
+				return openedKeystore{}, errors.New("keystore not found")
+			}
+			return openedKeystore{credentials: set}, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, i)
+		require.Equal(t, "old", opened.credentials.name)
+		require.Equal(t, []string{"new", "old"}, tried)
+	}
+
+	t.Log("does not fall back on integrity errors")
+	{
+		_, _, err := selectCredentials(sets, func(i int, set signingCredentials) (openedKeystore, error) {
+			return openedKeystore{}, keystoreIntegrityError{err: errors.New("digest mismatch")}
+		})
+		var integrityErr keystoreIntegrityError
+		require.True(t, errors.As(err, &integrityErr))
+	}
+
+	t.Log("lists the failure of every set")
+	{
+		_, _, err := selectCredentials(sets, func(i int, set signingCredentials) (openedKeystore, error) {
+			return openedKeystore{}, errors.New("wrong password")
+		})
+		require.EqualError(t, err, "none of the credential sets could be opened:\nnew: wrong password\nold: wrong password\noldest: wrong password")
+	}
+
+	t.Log("returns the error of a single set as is")
+	{
+		_, _, err := selectCredentials(sets[:1], func(i int, set signingCredentials) (openedKeystore, error) {
+			return openedKeystore{}, errors.New("wrong password")
+		})
+		require.EqualError(t, err, "wrong password")
+	}
+}
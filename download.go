@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// maxKeystoreSize is the largest response body accepted as a keystore.
+// Real keystores are a few kilobytes, anything close to this is not a keystore.
+const maxKeystoreSize = 10 * 1024 * 1024
+
+const defaultRetryWait = time.Second
+
+// maxRedirects is the redirect limit of the default net/http client.
+const maxRedirects = 10
+
+// downloadConfig configures the HTTP client used for downloading.
+type downloadConfig struct {
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	retries        int
+
+	headers           http.Header
+	basicAuthUsername string
+	basicAuthPassword string
+
+	// caBundlePath is a PEM file with additional trusted CA certificates.
+	caBundlePath string
+	// clientCertPath and clientKeyPath are PEM files used for mutual TLS.
+	clientCertPath string
+	clientKeyPath  string
+	// proxyURL overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	proxyURL string
+}
+
+// downloader fetches remote files with timeouts, retries and a response size cap.
+type downloader struct {
+	client      *http.Client
+	readTimeout time.Duration
+	retries     int
+	retryWait   time.Duration
+	maxSize     int64
+
+	headers           http.Header
+	basicAuthUsername string
+	basicAuthPassword string
+}
+
+// httpStatusError is returned when the server responds with a non-2xx status code.
+type httpStatusError struct {
+	url        string
+	statusCode int
+	status     string
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("%s responded with status: %s", e.url, e.status)
+}
+
+func newDownloader(config downloadConfig) (downloader, error) {
+	dialer := &net.Dialer{
+		Timeout:   config.connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	tlsConfig, err := newTLSConfig(config.caBundlePath, config.clientCertPath, config.clientKeyPath)
+	if err != nil {
+		return downloader{}, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.proxyURL != "" {
+		proxyURL, err := url.Parse(config.proxyURL)
+		if err != nil {
+			return downloader{}, fmt.Errorf("invalid proxy url (%s): %s", redactURL(config.proxyURL), redactURLError(err))
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.connectTimeout,
+		ResponseHeaderTimeout: config.readTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return downloader{
+		client:            &http.Client{Transport: transport, CheckRedirect: dropHeadersOnCrossHostRedirect(config.headers)},
+		readTimeout:       config.readTimeout,
+		retries:           config.retries,
+		retryWait:         defaultRetryWait,
+		maxSize:           maxKeystoreSize,
+		headers:           config.headers,
+		basicAuthUsername: config.basicAuthUsername,
+		basicAuthPassword: config.basicAuthPassword,
+	}, nil
+}
+
+func newTLSConfig(caBundlePath, clientCertPath, clientKeyPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if caBundlePath != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		caBundle, err := os.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %s", err)
+		}
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no PEM certificate found in CA bundle: %s", caBundlePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if clientCertPath != "" || clientKeyPath != "" {
+		if clientCertPath == "" || clientKeyPath == "" {
+			return nil, errors.New("both the client certificate and the client key are required for mutual TLS")
+		}
+
+		clientCert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseHeaders parses newline separated `Name: Value` pairs.
+func parseHeaders(s string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			// Do not include the line, it is most likely a secret.
+			return nil, errors.New("invalid header, expected format: 'Name: Value'")
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// parseBasicAuth parses a `username:password` pair.
+func parseBasicAuth(s string) (string, string, error) {
+	if s == "" {
+		return "", "", nil
+	}
+
+	username, password, found := strings.Cut(s, ":")
+	if !found || username == "" {
+		return "", "", errors.New("invalid basic auth credentials, expected format: 'username:password'")
+	}
+	return username, password, nil
+}
+
+// requestSigner authenticates a request right before it is sent, on every attempt.
+type requestSigner func(req *http.Request) error
+
+// dropHeadersOnCrossHostRedirect removes the custom download headers from redirects to another host,
+// as net/http only removes Authorization, Cookie and WWW-Authenticate headers from them.
+func dropHeadersOnCrossHostRedirect(headers http.Header) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if req.URL.Host != via[0].URL.Host {
+			for name := range headers {
+				req.Header.Del(name)
+			}
+		}
+		return nil
+	}
+}
+
+// download fetches rawURL into pth.
+// Network errors and 5xx responses are retried with exponential backoff,
+// any other non-2xx response fails immediately.
+func (d downloader) download(rawURL, pth string) error {
+	return d.downloadSigned(rawURL, pth, nil)
+}
+
+// downloadSigned is download with sign applied to each request, if not nil.
+func (d downloader) downloadSigned(rawURL, pth string, sign requestSigner) error {
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			wait := d.retryWait * time.Duration(1<<uint(attempt-1))
+			log.Warnf("Download failed: %s", err)
+			log.Printf("Retrying in %s (%d/%d)...", wait, attempt, d.retries)
+			time.Sleep(wait)
+		}
+
+		err = d.downloadOnce(rawURL, pth, sign)
+		if err == nil {
+			return nil
+		}
+
+		if removeErr := os.Remove(pth); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Warnf("Failed to remove partially downloaded file: %s", removeErr)
+		}
+
+		if !isRetryableDownloadError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (d downloader) downloadOnce(rawURL, pth string, sign requestSigner) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid download url (%s): %s", redactURL(rawURL), redactURLError(err))
+	}
+
+	for name, values := range d.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if d.basicAuthUsername != "" {
+		req.SetBasicAuth(d.basicAuthUsername, d.basicAuthPassword)
+	}
+	if sign != nil {
+		if err := sign(req); err != nil {
+			return fmt.Errorf("failed to sign request: %s", err)
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return httpStatusError{
+			url:        redactURL(rawURL),
+			statusCode: resp.StatusCode,
+			status:     resp.Status,
+		}
+	}
+
+	if resp.ContentLength > d.maxSize {
+		return fmt.Errorf("response size (%d bytes) exceeds the limit of %d bytes", resp.ContentLength, d.maxSize)
+	}
+
+	out, err := os.OpenFile(pth, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, secretFileMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			log.Warnf("Failed to close file: %s, error: %s", pth, err)
+		}
+	}()
+
+	body := newIdleTimeoutReader(resp.Body, d.readTimeout, cancel)
+	defer body.stop()
+
+	n, err := io.Copy(out, io.LimitReader(body, d.maxSize+1))
+	if err != nil {
+		return redactURLError(err)
+	}
+	if n > d.maxSize {
+		return fmt.Errorf("response size exceeds the limit of %d bytes", d.maxSize)
+	}
+	return nil
+}
+
+func isRetryableDownloadError(err error) bool {
+	var statusErr httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= 500
+	}
+
+	// *url.Error implements net.Error itself, classify by the wrapped error instead.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.Canceled)
+}
+
+// idleTimeoutReader cancels the underlying request when no data arrives within the timeout.
+type idleTimeoutReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimeoutReader(reader io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	r := &idleTimeoutReader{
+		reader:  reader,
+		timeout: timeout,
+	}
+	if timeout > 0 {
+		r.timer = time.AfterFunc(timeout, cancel)
+	}
+	return r
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.timer != nil {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleTimeoutReader) stop() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// redactURL hides the password part of the user info and every query parameter value,
+// as pre-signed storage URLs carry their credentials in the query.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "***"
+	}
+
+	redactPassword := false
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.User(u.User.Username())
+			redactPassword = true
+		}
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		keys := make([]string, 0, len(query))
+		for key := range query {
+			keys = append(keys, url.QueryEscape(key)+"=***")
+		}
+		sort.Strings(keys)
+		u.RawQuery = strings.Join(keys, "&")
+	}
+
+	if redactPassword {
+		// url.UserPassword would percent-encode the placeholder.
+		userInfo := u.User.String()
+		return strings.Replace(u.String(), userInfo+"@", userInfo+":***@", 1)
+	}
+	return u.String()
+}
+
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{
+			Op:  urlErr.Op,
+			URL: redactURL(urlErr.URL),
+			Err: urlErr.Err,
+		}
+	}
+	return err
+}
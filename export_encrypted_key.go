@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+const encryptedPrivateKeyPathEnvKey = "BITRISE_ENCRYPTED_PRIVATE_KEY_PATH"
+
+// exportEncryptedKey writes the private key of the keystore, encrypted to the encryption public key of Google Play,
+// to outputPath in the output format of the PEPK tool. Existing files are never overwritten.
+func exportEncryptedKey(source openedKeystore, encryptionPublicKeyPath, outputPath string, includeCertificate bool) error {
+	if err := ensureNotExists(outputPath); err != nil {
+		return err
+	}
+
+	ks, err := keystore.ReadFile(source.path, source.keystoreType, source.credentials.keystorePassword)
+	if errors.Is(err, keystore.ErrUnsupportedType) {
+		return fmt.Errorf("failed to read keystore: %w, convert it to a PKCS12 keystore with a single key first (convert_keystore mode)", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to read keystore: %w", err)
+	}
+	entry, err := ks.PrivateKeyEntry(source.credentials.alias, source.credentials.keyPassword)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	encryptionPublicKey, err := os.ReadFile(encryptionPublicKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read encryption public key: %s", err)
+	}
+	exported, err := keystore.ExportEncryptedKey(entry, encryptionPublicKey, includeCertificate)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputPath, exported, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted private key: %s", err)
+	}
+	return nil
+}
+
+func runExportEncryptedKey(cfg configs, ws *workspace, resolvers keystoreResolvers, vault *vaultClient) {
+	if cfg.EncryptionPublicKeyURL == "" {
+		failf("Process config: encryption_public_key_url is required in %s mode", exportEncryptedKeyMode)
+	}
+
+	source, err := openKeystore(ws, resolvers, vault, signingCredentials{
+		name:             "keystore_url",
+		keystoreURL:      string(cfg.KeystoreURL),
+		keystorePassword: string(cfg.KeystorePassword),
+		alias:            string(cfg.KeystoreAlias),
+		keyPassword:      string(cfg.PrivateKeyPassword),
+		keystoreSHA256:   cfg.KeystoreSHA256,
+	}, keystoreFileName, cfg.KeystoreType)
+	failOnKeystoreError("Run", err)
+	exportCertificateInfo(source.helper.CertificateInfo())
+
+	encryptionPublicKeyPath, _, err := resolvers.resolve(cfg.EncryptionPublicKeyURL, "encryption-public-key", ws)
+	if err != nil {
+		failf("Run: failed to get encryption public key: %s", err)
+	}
+
+	log.Infof("Export encrypted private key")
+	if err := exportEncryptedKey(source, encryptionPublicKeyPath, cfg.ExportOutputPath, cfg.ExportIncludeCertificate); err != nil {
+		failf("Run: failed to export encrypted private key: %s", err)
+	}
+
+	if err := tools.ExportEnvironmentWithEnvman(encryptedPrivateKeyPathEnvKey, cfg.ExportOutputPath); err != nil {
+		log.Warnf("Failed to export path (%s), error: %s", cfg.ExportOutputPath, err)
+	} else {
+		log.Donef("The path is now available in the Environment Variable: %s (value: %s)", encryptedPrivateKeyPathEnvKey, cfg.ExportOutputPath)
+	}
+	log.Printf("Upload the zip in the Play Console when enrolling the app in Play App Signing.")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportEncryptedKey(t *testing.T) {
+	dir := t.TempDir()
+	keystorePath, _, err := generateKeystore(dir, keystore.TypeJKS, keystore.GenerateOptions{
+		Alias:             "upload",
+		DistinguishedName: "CN=App Signing Key",
+		KeySpec:           keystore.KeySpecECP256,
+		ValidityDays:      10000,
+	}, "storepass", "keypass")
+	require.NoError(t, err)
+	helper, err := keystore.NewHelper(keystorePath, "storepass", "upload", "keypass", keystore.TypeJKS)
+	require.NoError(t, err)
+	source := openedKeystore{
+		credentials:  signingCredentials{keystorePassword: "storepass", alias: "upload", keyPassword: "keypass"},
+		path:         keystorePath,
+		keystoreType: keystore.TypeJKS,
+		helper:       helper,
+	}
+
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&encryptionKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyPath := filepath.Join(dir, "encryption_public_key.pem")
+	require.NoError(t, os.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0644))
+
+	t.Log("writes the encrypted key with the certificate")
+	{
+		outputPath := filepath.Join(dir, "deploy", "encrypted_private_key.zip")
+		require.NoError(t, exportEncryptedKey(source, publicKeyPath, outputPath, true))
+
+		r, err := zip.OpenReader(outputPath)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+		var names []string
+		for _, f := range r.File {
+			names = append(names, f.Name)
+		}
+		require.Equal(t, []string{keystore.PEPKEncryptedKeyEntry, keystore.PEPKCertificateEntry}, names)
+
+		t.Log("does not overwrite existing files")
+		require.Error(t, exportEncryptedKey(source, publicKeyPath, outputPath, true))
+	}
+
+	t.Log("fails with a wrong key password")
+	{
+		source.credentials.keyPassword = "wrong"
+		err := exportEncryptedKey(source, publicKeyPath, filepath.Join(dir, "wrong.zip"), false)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+// Step modes, selected by the mode input or the first command line argument.
+const (
+	signMode               = "sign"
+	generateKeystoreMode   = "generate_keystore"
+	convertKeystoreMode    = "convert_keystore"
+	exportEncryptedKeyMode = "export_encrypted_key"
+	createLineageMode      = "create_lineage"
+	inspectLineageMode     = "inspect_lineage"
+)
+
+const (
+	generatedKeystorePathEnvKey    = "BITRISE_GENERATED_KEYSTORE_PATH"
+	generatedCertificatePathEnvKey = "BITRISE_GENERATED_CERTIFICATE_PATH"
+
+	defaultGeneratedAlias = "upload"
+)
+
+// stepMode returns the mode of the step, the first command line argument overrides the mode input.
+func stepMode(modeInput string, args []string) (string, error) {
+	mode := modeInput
+	if len(args) > 1 {
+		mode = args[1]
+	}
+
+	switch mode {
+	case "":
+		return signMode, nil
+	case signMode, generateKeystoreMode, convertKeystoreMode, exportEncryptedKeyMode, createLineageMode, inspectLineageMode:
+		return mode, nil
+	default:
+		modes := []string{signMode, generateKeystoreMode, convertKeystoreMode, exportEncryptedKeyMode, createLineageMode, inspectLineageMode}
+		return "", fmt.Errorf("unknown mode: %s, available modes: %s", mode, strings.Join(modes, ", "))
+	}
+}
+
+// generatedKeystoreType returns the type of the keystore to generate from the keystore_type input, PKCS#12 by default.
+func generatedKeystoreType(typeInput string) (keystore.Type, error) {
+	if typeInput == "" || typeInput == "automatic" {
+		return keystore.TypePKCS12, nil
+	}
+
+	keystoreType, err := keystore.ParseType(typeInput)
+	if err != nil {
+		return keystore.TypeUnknown, err
+	}
+	if keystoreType != keystore.TypePKCS12 && keystoreType != keystore.TypeJKS {
+		return keystore.TypeUnknown, fmt.Errorf("only pkcs12 and jks keystores can be generated, keystore_type is: %s", typeInput)
+	}
+	return keystoreType, nil
+}
+
+// generateKeystore writes a new keystore with a self-signed upload key and its certificate in PEM format into outputDir.
+// Existing files are never overwritten.
+func generateKeystore(outputDir string, keystoreType keystore.Type, opts keystore.GenerateOptions, storePassword, keyPassword string) (keystorePath, certificatePath string, err error) {
+	keystorePath = filepath.Join(outputDir, keystoreFileName+keystoreType.Extension())
+	certificatePath = filepath.Join(outputDir, "certificate.pem")
+	for _, pth := range []string{keystorePath, certificatePath} {
+		if err := ensureNotExists(pth); err != nil {
+			return "", "", err
+		}
+	}
+
+	entry, err := keystore.Generate(opts)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := keystore.Encode(keystoreType, entry, storePassword, keyPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode keystore: %s", err)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(keystorePath, data, 0600); err != nil {
+		return "", "", fmt.Errorf("failed to write keystore: %s", err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: entry.Certificate().Raw})
+	if err := os.WriteFile(certificatePath, certificate, 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write certificate: %s", err)
+	}
+	return keystorePath, certificatePath, nil
+}
+
+func runGenerateKeystore(cfg configs) {
+	keystoreType, err := generatedKeystoreType(cfg.KeystoreType)
+	if err != nil {
+		failf("Process config: %s", err)
+	}
+	storePassword := string(cfg.KeystorePassword)
+	keyPassword, ignored := keystore.SignerKeyPassword(keystoreType, storePassword, string(cfg.PrivateKeyPassword))
+	if ignored {
+		log.Warnf("The key password differs from the keystore password, but keys of PKCS12 keystores are protected with the keystore password, the key password is ignored.")
+	}
+	alias := string(cfg.KeystoreAlias)
+	if alias == "" {
+		alias = defaultGeneratedAlias
+		log.Printf("keystore_alias is empty, using alias: %s", alias)
+	}
+
+	log.Infof("Generate %s keystore", keystoreType.StoreType())
+	keystorePath, certificatePath, err := generateKeystore(cfg.GenerateOutputDir, keystoreType, keystore.GenerateOptions{
+		Alias:             alias,
+		DistinguishedName: cfg.GenerateDistinguishedName,
+		KeySpec:           keystore.KeySpec(cfg.GenerateKeyAlgorithm),
+		ValidityDays:      cfg.GenerateValidityDays,
+	}, storePassword, keyPassword)
+	if err != nil {
+		failf("Run: failed to generate keystore: %s", err)
+	}
+
+	helper, err := keystore.NewHelper(keystorePath, storePassword, alias, keyPassword, keystoreType)
+	if err != nil {
+		failf("Run: failed to open the generated keystore: %s", err)
+	}
+	exportCertificateInfo(helper.CertificateInfo())
+
+	for _, output := range []struct {
+		envKey string
+		pth    string
+	}{
+		{generatedKeystorePathEnvKey, keystorePath},
+		{generatedCertificatePathEnvKey, certificatePath},
+	} {
+		if err := tools.ExportEnvironmentWithEnvman(output.envKey, output.pth); err != nil {
+			log.Warnf("Failed to export path (%s), error: %s", output.pth, err)
+		} else {
+			log.Donef("The path is now available in the Environment Variable: %s (value: %s)", output.envKey, output.pth)
+		}
+	}
+	log.Warnf("Store the keystore and its passwords safely (e.g. upload the keystore to the Code Signing & Files tab), a lost upload key can only be reset through Google Play support.")
+}
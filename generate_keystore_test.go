@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		modeInput string
+		args      []string
+		want      string
+		wantErr   bool
+	}{
+		{name: "default", args: []string{"steps-sign-apk"}, want: signMode},
+		{name: "mode input", modeInput: "generate_keystore", args: []string{"steps-sign-apk"}, want: generateKeystoreMode},
+		{name: "command line argument overrides the input", modeInput: "sign", args: []string{"steps-sign-apk", "generate_keystore"}, want: generateKeystoreMode},
+		{name: "convert keystore", args: []string{"steps-sign-apk", "convert_keystore"}, want: convertKeystoreMode},
+		{name: "export encrypted key", modeInput: "export_encrypted_key", args: []string{"steps-sign-apk"}, want: exportEncryptedKeyMode},
+		{name: "lineage modes", args: []string{"steps-sign-apk", "inspect_lineage"}, want: inspectLineageMode},
+		{name: "unknown mode", args: []string{"steps-sign-apk", "verify"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stepMode(tt.modeInput, tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerateKeystore(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "deploy")
+	opts := keystore.GenerateOptions{
+		Alias:             "upload",
+		DistinguishedName: "CN=Upload Key, O=Bitrise",
+		KeySpec:           keystore.KeySpecECP256,
+		ValidityDays:      10000,
+	}
+
+	t.Log("writes the keystore and the certificate")
+	{
+		keystoreType, err := generatedKeystoreType("automatic")
+		require.NoError(t, err)
+
+		keystorePath, certificatePath, err := generateKeystore(outputDir, keystoreType, opts, "storepass", "")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(outputDir, "keystore.p12"), keystorePath)
+
+		helper, err := keystore.NewHelper(keystorePath, "storepass", "upload", "", keystoreType)
+		require.NoError(t, err)
+		require.Equal(t, "CN=Upload Key,O=Bitrise", helper.CertificateInfo().Subject)
+
+		certificate, err := os.ReadFile(certificatePath)
+		require.NoError(t, err)
+		require.Contains(t, string(certificate), "-----BEGIN CERTIFICATE-----")
+	}
+
+	t.Log("does not overwrite existing files")
+	{
+		_, _, err := generateKeystore(outputDir, keystore.TypePKCS12, opts, "storepass", "")
+		require.Error(t, err)
+	}
+
+	t.Log("generates PKCS12 keystores by default and JKS keystores only besides them")
+	{
+		keystoreType, err := generatedKeystoreType("automatic")
+		require.NoError(t, err)
+		require.Equal(t, keystore.TypePKCS12, keystoreType)
+
+		keystoreType, err = generatedKeystoreType("jks")
+		require.NoError(t, err)
+		require.Equal(t, keystore.TypeJKS, keystoreType)
+
+		_, err = generatedKeystoreType("bks")
+		require.Error(t, err)
+	}
+}
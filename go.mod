@@ -1,12 +1,23 @@
 module github.com/bitrise-steplib/steps-sign-apk
 
-go 1.16
+go 1.19
 
 require (
 	github.com/avast/apkparser v0.0.0-20210301101811-6256c76f738e
 	github.com/bitrise-io/go-android v0.0.0-20210527143215-3ad22ad02e2e
 	github.com/bitrise-io/go-steputils v0.0.0-20210527075147-910ce7a105a1
 	github.com/bitrise-io/go-utils v0.0.0-20210713111255-08be784d45d0
-	github.com/klauspost/compress v1.13.2 // indirect
+	github.com/pavlo-v-chernykh/keystore-go/v4 v4.5.0
 	github.com/stretchr/testify v1.7.0
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/crypto v0.22.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/hashicorp/go-version v1.3.0 // indirect
+	github.com/klauspost/compress v1.13.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
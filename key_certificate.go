@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+// keyCertificateAlias is the alias of the key in the keystore built for jarsigner from private_key_url and certificate_url.
+const keyCertificateAlias = "signing"
+
+// keyCertificateFiles are the private key (unencrypted PKCS#8 DER) and certificate chain (PEM) passed to apksigner.
+type keyCertificateFiles struct {
+	keyPath         string
+	certificatePath string
+}
+
+// openKeyCertificate reads the private key and the certificate chain from their urls.
+// As jarsigner signs with keystores only, the key is also stored in a PKCS#12 keystore with a random password in the workspace, encoded in memory.
+func openKeyCertificate(ws *workspace, resolvers keystoreResolvers, vault *vaultClient, keyURL, certificateURL, keyPassword string) (openedKeystore, *keyCertificateFiles, error) {
+	keyPassword, err := resolveVaultSecret(vault, "key password", keyPassword)
+	if err != nil {
+		return openedKeystore{}, nil, err
+	}
+
+	var contents [][]byte
+	for _, source := range []struct {
+		name     string
+		url      string
+		fileName string
+	}{
+		{"private key", keyURL, "private-key"},
+		{"certificate", certificateURL, "certificate"},
+	} {
+		pth, _, err := resolvers.resolve(source.url, source.fileName, ws)
+		if err != nil {
+			return openedKeystore{}, nil, fmt.Errorf("failed to get %s: %s", source.name, err)
+		}
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			return openedKeystore{}, nil, fmt.Errorf("failed to read %s: %s", source.name, err)
+		}
+		contents = append(contents, content)
+	}
+
+	entry, err := keystore.ParseKeyCertificate(keyCertificateAlias, contents[0], contents[1], keyPassword)
+	if err != nil {
+		return openedKeystore{}, nil, err
+	}
+	files, err := writeKeyCertificateFiles(ws, entry)
+	if err != nil {
+		return openedKeystore{}, nil, err
+	}
+
+	storePassword, err := randomPassword()
+	if err != nil {
+		return openedKeystore{}, nil, err
+	}
+	data, err := keystore.Encode(keystore.TypePKCS12, entry, storePassword, "")
+	if err != nil {
+		return openedKeystore{}, nil, fmt.Errorf("failed to create keystore for jarsigner: %s", err)
+	}
+	keystorePath, err := ws.writeSecret(keystoreFileName+keystore.TypePKCS12.Extension(), data)
+	if err != nil {
+		return openedKeystore{}, nil, fmt.Errorf("failed to write keystore for jarsigner: %s", err)
+	}
+	log.Printf("using private key and certificate, keystore for jarsigner at: %s", keystorePath)
+
+	helper, err := keystore.NewHelper(keystorePath, storePassword, keyCertificateAlias, "", keystore.TypePKCS12)
+	if err != nil {
+		return openedKeystore{}, nil, fmt.Errorf("failed to open keystore: %w", err)
+	}
+
+	return openedKeystore{
+		credentials: signingCredentials{
+			name:             "private_key_url",
+			keystorePassword: storePassword,
+			alias:            keyCertificateAlias,
+		},
+		path:         keystorePath,
+		keystoreType: keystore.TypePKCS12,
+		helper:       helper,
+	}, files, nil
+}
+
+// writeKeyCertificateFiles writes the key and the certificate chain in the formats read by apksigner.
+func writeKeyCertificateFiles(ws *workspace, entry keystore.PrivateKeyEntry) (*keyCertificateFiles, error) {
+	key, err := keystore.MarshalPKCS8PrivateKey(entry.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %s", err)
+	}
+	keyPath, err := ws.writeSecret("private-key.pk8", key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write private key: %s", err)
+	}
+
+	var chain []byte
+	for _, cert := range entry.CertificateChain {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	certificatePath := ws.path("certificate.pem")
+	if err := os.WriteFile(certificatePath, chain, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write certificate: %s", err)
+	}
+
+	return &keyCertificateFiles{keyPath: keyPath, certificatePath: certificatePath}, nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenKeyCertificate(t *testing.T) {
+	entry, err := keystore.Generate(keystore.GenerateOptions{
+		Alias:             "key",
+		DistinguishedName: "CN=Signing Key",
+		KeySpec:           keystore.KeySpecECP256,
+		ValidityDays:      10000,
+	})
+	require.NoError(t, err)
+	key, err := keystore.MarshalPKCS8PrivateKey(entry.PrivateKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600))
+	certificatePath := filepath.Join(dir, "certificate.der")
+	require.NoError(t, os.WriteFile(certificatePath, entry.Certificate().Raw, 0600))
+
+	resolvers := newKeystoreResolvers(downloader{}, s3Config{}, nil)
+	opened, files, err := openKeyCertificate(newTestWorkspace(t), resolvers, nil, "file://"+keyPath, "file://"+certificatePath, "")
+	require.NoError(t, err)
+
+	t.Log("the keystore for jarsigner holds the key and its certificate")
+	{
+		require.Equal(t, keystore.TypePKCS12, opened.keystoreType)
+		require.Equal(t, keyCertificateAlias, opened.helper.Alias())
+		require.Equal(t, keystore.NewCertificateInfo(entry.Certificate()), opened.helper.CertificateInfo())
+	}
+
+	t.Log("apksigner gets the key in PKCS#8 DER and the certificate in PEM format")
+	{
+		info, err := os.Stat(files.keyPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+		content, err := os.ReadFile(files.keyPath)
+		require.NoError(t, err)
+		require.Equal(t, key, content)
+
+		certificate, err := os.ReadFile(files.certificatePath)
+		require.NoError(t, err)
+		require.Equal(t, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: entry.Certificate().Raw}), certificate)
+	}
+
+	t.Log("fails if the certificate does not belong to the key")
+	{
+		other, err := keystore.Generate(keystore.GenerateOptions{
+			Alias:             "other",
+			DistinguishedName: "CN=Other Key",
+			KeySpec:           keystore.KeySpecECP256,
+			ValidityDays:      1,
+		})
+		require.NoError(t, err)
+		otherCertificatePath := filepath.Join(dir, "other.der")
+		require.NoError(t, os.WriteFile(otherCertificatePath, other.Certificate().Raw, 0600))
+
+		_, _, err = openKeyCertificate(newTestWorkspace(t), resolvers, nil, "file://"+keyPath, "file://"+otherCertificatePath, "")
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+// keyPolicy is the signing key allowed by the allowed_key_algorithms, min_key_sizes and
+// allowed_certificate_signature_algorithms inputs.
+type keyPolicy struct {
+	keyAlgorithms []string
+	// minKeySizes maps key algorithms to their minimum size in bits.
+	minKeySizes                    map[string]int
+	certificateSignatureAlgorithms []string
+}
+
+// javaSignatureAlgorithms maps the signature algorithm names of keytool and jarsigner to the names of crypto/x509,
+// which are printed in the log. RSASSA-PSS stands for the PSS signatures of every digest.
+var javaSignatureAlgorithms = map[string][]string{
+	"MD2WITHRSA":      {"MD2-RSA"},
+	"MD5WITHRSA":      {"MD5-RSA"},
+	"SHA1WITHRSA":     {"SHA1-RSA"},
+	"SHA256WITHRSA":   {"SHA256-RSA"},
+	"SHA384WITHRSA":   {"SHA384-RSA"},
+	"SHA512WITHRSA":   {"SHA512-RSA"},
+	"RSASSA-PSS":      {"SHA256-RSAPSS", "SHA384-RSAPSS", "SHA512-RSAPSS"},
+	"SHA1WITHDSA":     {"DSA-SHA1"},
+	"SHA256WITHDSA":   {"DSA-SHA256"},
+	"SHA1WITHECDSA":   {"ECDSA-SHA1"},
+	"SHA256WITHECDSA": {"ECDSA-SHA256"},
+	"SHA384WITHECDSA": {"ECDSA-SHA384"},
+	"SHA512WITHECDSA": {"ECDSA-SHA512"},
+}
+
+// parseSignatureAlgorithms returns the crypto/x509 names of the signature algorithms,
+// which are given either with their crypto/x509 or with their keytool name.
+func parseSignatureAlgorithms(list string) ([]string, error) {
+	var algorithms []string
+	for _, name := range parseList(list) {
+		if names, ok := javaSignatureAlgorithms[strings.ToUpper(name)]; ok {
+			algorithms = append(algorithms, names...)
+			continue
+		}
+
+		known := false
+		for algorithm := x509.MD2WithRSA; algorithm <= x509.PureEd25519; algorithm++ {
+			if strings.EqualFold(algorithm.String(), name) {
+				algorithms = append(algorithms, algorithm.String())
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("allowed_certificate_signature_algorithms: unknown signature algorithm (%s), expected a name like SHA256-RSA or SHA256withRSA", name)
+		}
+	}
+	return algorithms, nil
+}
+
+func parseKeyPolicy(keyAlgorithms, minKeySizes, certificateSignatureAlgorithms string) (keyPolicy, error) {
+	signatureAlgorithms, err := parseSignatureAlgorithms(certificateSignatureAlgorithms)
+	if err != nil {
+		return keyPolicy{}, err
+	}
+	policy := keyPolicy{
+		keyAlgorithms:                  parseList(keyAlgorithms),
+		minKeySizes:                    map[string]int{},
+		certificateSignatureAlgorithms: signatureAlgorithms,
+	}
+
+	for _, minKeySize := range parseList(minKeySizes) {
+		algorithm, size, found := strings.Cut(minKeySize, ":")
+		algorithm = strings.TrimSpace(algorithm)
+		bits, err := strconv.Atoi(strings.TrimSpace(size))
+		if !found || algorithm == "" || err != nil || bits < 0 {
+			return keyPolicy{}, fmt.Errorf("min_key_sizes: invalid key size (%s), expected format: ALGORITHM:BITS (e.g. RSA:2048)", minKeySize)
+		}
+		policy.minKeySizes[strings.ToUpper(algorithm)] = bits
+	}
+	return policy, nil
+}
+
+// check returns an error naming the first property of the signing key which is not allowed.
+// Empty allow-lists allow everything.
+func (policy keyPolicy) check(certificate keystore.CertificateInfo) error {
+	if len(policy.keyAlgorithms) > 0 && !containsFold(policy.keyAlgorithms, certificate.PublicKeyAlgorithm) {
+		return fmt.Errorf("key algorithm %s is not allowed (allowed_key_algorithms: %s), sign with a key of an allowed algorithm or extend allowed_key_algorithms",
+			certificate.PublicKeyAlgorithm, strings.Join(policy.keyAlgorithms, ", "))
+	}
+
+	if minKeySize, ok := policy.minKeySizes[strings.ToUpper(certificate.PublicKeyAlgorithm)]; ok && certificate.PublicKeySize < minKeySize {
+		return fmt.Errorf("key size %d bits of the %s key is below the minimum of %d bits (min_key_sizes), sign with a larger key or lower min_key_sizes",
+			certificate.PublicKeySize, certificate.PublicKeyAlgorithm, minKeySize)
+	}
+
+	if len(policy.certificateSignatureAlgorithms) > 0 && !containsFold(policy.certificateSignatureAlgorithms, certificate.SignatureAlgorithm) {
+		return fmt.Errorf("certificate signature algorithm %s is not allowed (allowed_certificate_signature_algorithms: %s), reissue the certificate with an allowed algorithm or extend allowed_certificate_signature_algorithms",
+			certificate.SignatureAlgorithm, strings.Join(policy.certificateSignatureAlgorithms, ", "))
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyPolicy(t *testing.T) {
+	t.Log("parses the default inputs")
+	{
+		policy, err := parseKeyPolicy("RSA|EC", "RSA:2048|EC:256", "SHA256-RSA\nECDSA-SHA256")
+		require.NoError(t, err)
+		require.Equal(t, keyPolicy{
+			keyAlgorithms:                  []string{"RSA", "EC"},
+			minKeySizes:                    map[string]int{"RSA": 2048, "EC": 256},
+			certificateSignatureAlgorithms: []string{"SHA256-RSA", "ECDSA-SHA256"},
+		}, policy)
+	}
+
+	t.Log("accepts the signature algorithm names of keytool")
+	{
+		policy, err := parseKeyPolicy("", "", "SHA256withRSA|sha256-rsa|SHA384withECDSA|RSASSA-PSS|Ed25519")
+		require.NoError(t, err)
+		require.Equal(t, []string{"SHA256-RSA", "SHA256-RSA", "ECDSA-SHA384", "SHA256-RSAPSS", "SHA384-RSAPSS", "SHA512-RSAPSS", "Ed25519"}, policy.certificateSignatureAlgorithms)
+	}
+
+	t.Log("rejects unknown signature algorithms")
+	{
+		_, err := parseKeyPolicy("", "", "SHA256-RSA|SHA256withRSA2")
+		require.EqualError(t, err, "allowed_certificate_signature_algorithms: unknown signature algorithm (SHA256withRSA2), expected a name like SHA256-RSA or SHA256withRSA")
+	}
+
+	t.Log("rejects invalid key sizes")
+	{
+		for _, minKeySizes := range []string{"RSA", "RSA:large", ":2048", "RSA:-1"} {
+			_, err := parseKeyPolicy("", minKeySizes, "")
+			require.Error(t, err, minKeySizes)
+		}
+	}
+}
+
+func TestKeyPolicyCheck(t *testing.T) {
+	policy, err := parseKeyPolicy("RSA|EC", "rsa:2048|EC:256", "SHA256-RSA|ECDSA-SHA256")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		policy      keyPolicy
+		certificate keystore.CertificateInfo
+		wantErr     string
+	}{
+		{
+			name:        "allowed RSA key",
+			policy:      policy,
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "RSA", PublicKeySize: 2048, SignatureAlgorithm: "SHA256-RSA"},
+		},
+		{
+			name:        "allowed EC key",
+			policy:      policy,
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "EC", PublicKeySize: 256, SignatureAlgorithm: "ECDSA-SHA256"},
+		},
+		{
+			name:        "DSA key",
+			policy:      policy,
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "DSA", PublicKeySize: 2048, SignatureAlgorithm: "SHA256-RSA"},
+			wantErr:     "key algorithm DSA is not allowed",
+		},
+		{
+			name:        "small RSA key",
+			policy:      policy,
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "RSA", PublicKeySize: 1024, SignatureAlgorithm: "SHA256-RSA"},
+			wantErr:     "key size 1024 bits of the RSA key is below the minimum of 2048 bits",
+		},
+		{
+			name:        "SHA-1 certificate signature",
+			policy:      policy,
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "RSA", PublicKeySize: 4096, SignatureAlgorithm: "SHA1-RSA"},
+			wantErr:     "certificate signature algorithm SHA1-RSA is not allowed",
+		},
+		{
+			name:        "empty policy",
+			policy:      keyPolicy{},
+			certificate: keystore.CertificateInfo{PublicKeyAlgorithm: "DSA", PublicKeySize: 1024, SignatureAlgorithm: "MD5-RSA"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.check(tt.certificate)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
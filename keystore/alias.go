@@ -0,0 +1,74 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAliasRequired is returned when no alias is given and the keystore does not have exactly one private key entry.
+var ErrAliasRequired = errors.New("alias is required")
+
+// defaultAlias returns the alias of the only private key entry of the keystore.
+func defaultAlias(ks *Keystore) (string, error) {
+	aliases := ks.Aliases()
+	switch len(aliases) {
+	case 0:
+		return "", errors.New("keystore has no private key entry")
+	case 1:
+		return aliases[0], nil
+	default:
+		return "", fmt.Errorf("%w, the keystore has %d private key entries: %s", ErrAliasRequired, len(aliases), strings.Join(aliases, ", "))
+	}
+}
+
+// aliasNotFoundError lists the aliases of the keystore and the closest match of alias.
+func aliasNotFoundError(err error, alias string, aliases []string) error {
+	if len(aliases) == 0 {
+		return fmt.Errorf("%w, the keystore has no private key entry", err)
+	}
+	return fmt.Errorf("%w, aliases in the keystore: %s (closest match: %s)", err, strings.Join(aliases, ", "), closestAlias(alias, aliases))
+}
+
+// closestAlias returns the alias with the smallest case-insensitive edit distance to alias.
+func closestAlias(alias string, aliases []string) string {
+	closest, minDistance := "", -1
+	for _, candidate := range aliases {
+		if distance := levenshtein(strings.ToLower(alias), strings.ToLower(candidate)); minDistance < 0 || distance < minDistance {
+			closest, minDistance = candidate, distance
+		}
+	}
+	return closest
+}
+
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
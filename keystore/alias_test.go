@@ -0,0 +1,57 @@
+package keystore
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultAlias(t *testing.T) {
+	privateKeyEntry := func(alias string) keystoreEntry {
+		return keystoreEntry{
+			alias:      alias,
+			decryptKey: func(string) (crypto.PrivateKey, error) { return nil, nil },
+		}
+	}
+
+	t.Log("selects the only private key entry, trusted certificates are ignored")
+	{
+		alias, err := defaultAlias(&Keystore{entries: []keystoreEntry{privateKeyEntry("upload"), {alias: "ca"}}})
+		require.NoError(t, err)
+		require.Equal(t, "upload", alias)
+	}
+
+	t.Log("requires the alias if there are more private key entries")
+	{
+		_, err := defaultAlias(&Keystore{entries: []keystoreEntry{privateKeyEntry("upload"), privateKeyEntry("release")}})
+		require.True(t, errors.Is(err, ErrAliasRequired))
+		require.Contains(t, err.Error(), "release, upload")
+	}
+
+	t.Log("fails if there is no private key entry")
+	{
+		_, err := defaultAlias(&Keystore{entries: []keystoreEntry{{alias: "ca"}}})
+		require.Error(t, err)
+	}
+}
+
+func TestClosestAlias(t *testing.T) {
+	aliases := []string{"debug", "release", "upload"}
+
+	tests := []struct {
+		alias string
+		want  string
+	}{
+		{alias: "Upload", want: "upload"},
+		{alias: "uplod", want: "upload"},
+		{alias: "release-key", want: "release"},
+		{alias: "debg", want: "debug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			require.Equal(t, tt.want, closestAlias(tt.alias, aliases))
+		})
+	}
+}
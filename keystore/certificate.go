@@ -0,0 +1,74 @@
+package keystore
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateInfo describes a signing certificate, with the details printed by `keytool -list -v`.
+type CertificateInfo struct {
+	Subject            string
+	Issuer             string
+	SerialNumber       string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	PublicKeyAlgorithm string
+	PublicKeySize      int
+	SignatureAlgorithm string
+
+	MD5    Fingerprint
+	SHA1   Fingerprint
+	SHA256 Fingerprint
+}
+
+// Fingerprint is a digest of the DER encoded certificate.
+type Fingerprint struct {
+	// Hex is the uppercase, colon separated hex form, as printed by keytool and required by Firebase and Google Sign-In.
+	Hex string
+	// Base64 is the URL-safe, unpadded base64 form, as reported in Play Integrity verdicts.
+	Base64 string
+}
+
+// NewCertificateInfo collects the details of cert.
+func NewCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	md5Digest := md5.Sum(cert.Raw)
+	sha1Digest := sha1.Sum(cert.Raw)
+	sha256Digest := sha256.Sum256(cert.Raw)
+
+	return CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		PublicKeyAlgorithm: publicKeyAlgorithm(cert.PublicKey),
+		PublicKeySize:      publicKeySize(cert.PublicKey),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		MD5:                newFingerprint(md5Digest[:]),
+		SHA1:               newFingerprint(sha1Digest[:]),
+		SHA256:             newFingerprint(sha256Digest[:]),
+	}
+}
+
+// CertificateInfo returns the details of the certificate of the signing key.
+func (helper Helper) CertificateInfo() CertificateInfo {
+	return NewCertificateInfo(helper.Certificate())
+}
+
+func newFingerprint(digest []byte) Fingerprint {
+	hexBytes := make([]string, len(digest))
+	for i, b := range digest {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return Fingerprint{
+		Hex:    strings.Join(hexBytes, ":"),
+		Base64: base64.RawURLEncoding.EncodeToString(digest),
+	}
+}
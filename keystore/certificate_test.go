@@ -0,0 +1,29 @@
+package keystore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateInfo(t *testing.T) {
+	helper, err := NewHelper("testdata/rsa-chain.p12", "storepass", "upload", "", TypePKCS12)
+	require.NoError(t, err)
+
+	info := helper.CertificateInfo()
+	require.Equal(t, "CN=Upload Key,O=Bitrise", info.Subject)
+	require.Equal(t, "CN=Test CA", info.Issuer)
+	require.Equal(t, "2924a891899514f2560a845b963474ba50c59950", info.SerialNumber)
+	require.Equal(t, time.Date(2026, 10, 16, 17, 50, 3, 0, time.UTC), info.NotBefore)
+	require.Equal(t, time.Date(2126, 9, 22, 17, 50, 3, 0, time.UTC), info.NotAfter)
+	require.Equal(t, "RSA", info.PublicKeyAlgorithm)
+	require.Equal(t, 2048, info.PublicKeySize)
+	require.Equal(t, "SHA256-RSA", info.SignatureAlgorithm)
+
+	// Compared to the output of `openssl x509 -noout -fingerprint`
+	require.Equal(t, "95:56:88:A3:32:C6:5B:09:5D:3F:96:BE:49:89:42:07", info.MD5.Hex)
+	require.Equal(t, "0E:B4:6E:51:23:20:0E:B8:24:FF:8B:2B:4A:69:B9:AD:30:E0:EA:CA", info.SHA1.Hex)
+	require.Equal(t, "9C:B7:E2:EE:A5:C2:51:D1:75:E5:CC:2F:08:F6:01:12:3D:B7:16:E7:7E:9D:BD:F5:64:53:43:7C:8E:5F:63:37", info.SHA256.Hex)
+	require.Equal(t, "DrRuUSMgDrgk_4srSmm5rTDg6so", info.SHA1.Base64)
+}
@@ -0,0 +1,248 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ConvertToPKCS12 converts a JKS or JCEKS keystore to PKCS#12 with keytool -importkeystore,
+// which keeps the aliases, the keys and the certificate chains.
+// The keys are decrypted with the store password. keytool takes a distinct key password for a single alias only,
+// so if keyPassword differs from the store password, only the entry of alias is converted.
+// The PKCS#12 keystore and its keys are protected with newPassword, or with the store password if it is empty.
+// The file at outputPth must not exist.
+func ConvertToPKCS12(keystorePth string, keystoreType Type, storePassword, alias, keyPassword, outputPth, newPassword string) error {
+	if keystoreType != TypeJKS && keystoreType != TypeJCEKS {
+		return fmt.Errorf("only JKS and JCEKS keystores can be converted to PKCS12, the keystore is: %s", keystoreType.StoreType())
+	}
+	if newPassword == "" {
+		newPassword = storePassword
+	}
+
+	cmdSlice := []string{
+		"keytool",
+		"-importkeystore",
+		"-noprompt",
+
+		"-srckeystore",
+		keystorePth,
+		"-srcstoretype",
+		keystoreType.StoreType(),
+		"-srcstorepass",
+		storePassword,
+
+		"-destkeystore",
+		outputPth,
+		"-deststoretype",
+		TypePKCS12.StoreType(),
+		"-deststorepass",
+		newPassword,
+
+		"-J-Dfile.encoding=utf-8",
+		"-J-Duser.language=en-US",
+	}
+
+	if keyPassword != "" && keyPassword != storePassword {
+		if alias == "" {
+			return fmt.Errorf("%w to convert a keystore with a key password other than the keystore password", ErrAliasRequired)
+		}
+		cmdSlice = append(cmdSlice, "-srcalias", alias, "-srckeypass", keyPassword, "-destalias", alias)
+	}
+
+	out, err := ExecuteForOutput(cmdSlice)
+	if err != nil {
+		return properError(err, out)
+	}
+	return nil
+}
+
+// VerifyConversion checks that the PKCS#12 keystore written by ConvertToPKCS12 holds the private key entries of the source keystore:
+// the same aliases (only alias, if keyPassword differs from the store password) with the same certificate chains,
+// and every converted private key belongs to the certificate of its entry.
+func VerifyConversion(keystorePth string, keystoreType Type, storePassword, alias, keyPassword, convertedPth, newPassword string) error {
+	if newPassword == "" {
+		newPassword = storePassword
+	}
+
+	sourceChains, err := listCertificateChains(keystorePth, keystoreType, storePassword)
+	if err != nil {
+		return fmt.Errorf("failed to list the entries of the keystore: %s", err)
+	}
+	if keyPassword != "" && keyPassword != storePassword {
+		chain, ok := sourceChains[strings.ToLower(alias)]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+		}
+		sourceChains = map[string][]*x509.Certificate{strings.ToLower(alias): chain}
+	}
+
+	data, err := os.ReadFile(convertedPth)
+	if err != nil {
+		return err
+	}
+	converted, err := readPKCS12Entries(data, newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to read the converted keystore: %w", err)
+	}
+	return verifyConvertedEntries(sourceChains, converted)
+}
+
+// verifyConvertedEntries compares the converted entries to the certificate chains of the source keystore, by lowercase alias.
+func verifyConvertedEntries(sourceChains map[string][]*x509.Certificate, converted map[string]PrivateKeyEntry) error {
+	var sourceAliases, convertedAliases []string
+	for alias := range sourceChains {
+		sourceAliases = append(sourceAliases, alias)
+	}
+	for alias := range converted {
+		convertedAliases = append(convertedAliases, alias)
+	}
+	sort.Strings(sourceAliases)
+	sort.Strings(convertedAliases)
+	if strings.Join(sourceAliases, ", ") != strings.Join(convertedAliases, ", ") {
+		return fmt.Errorf("aliases of the converted keystore (%s) differ from the aliases of the keystore (%s)", strings.Join(convertedAliases, ", "), strings.Join(sourceAliases, ", "))
+	}
+
+	for _, alias := range sourceAliases {
+		entry := converted[alias]
+		if got, want := chainDigest(entry.CertificateChain), chainDigest(sourceChains[alias]); got != want {
+			return fmt.Errorf("entry (%s): certificate chain of the converted keystore (%s) differs from the original one (%s)", alias, got, want)
+		}
+		if !matchesPublicKey(entry.PrivateKey, entry.Certificate().PublicKey) {
+			return fmt.Errorf("entry (%s): private key of the converted keystore does not belong to its certificate", alias)
+		}
+	}
+	return nil
+}
+
+// chainDigest returns the SHA-256 digest of the DER encoded certificates of the chain, in hex form.
+func chainDigest(chain []*x509.Certificate) string {
+	h := sha256.New()
+	for _, cert := range chain {
+		h.Write(cert.Raw)
+	}
+	return fmt.Sprintf("%X", h.Sum(nil))
+}
+
+// listCertificateChains returns the certificate chains of the private key entries of the keystore by lowercase alias,
+// read with keytool -list, as JCEKS keystores are not supported by the native reader.
+func listCertificateChains(keystorePth string, keystoreType Type, storePassword string) (map[string][]*x509.Certificate, error) {
+	out, err := ExecuteForOutput([]string{
+		"keytool",
+		"-list",
+		"-rfc",
+
+		"-keystore",
+		keystorePth,
+		"-storetype",
+		keystoreType.StoreType(),
+		"-storepass",
+		storePassword,
+
+		"-J-Dfile.encoding=utf-8",
+		"-J-Duser.language=en-US",
+	})
+	if err != nil {
+		return nil, properError(err, out)
+	}
+	return parseKeytoolList(out)
+}
+
+// parseKeytoolList parses the private key entries of the output of keytool -list -rfc.
+func parseKeytoolList(out string) (map[string][]*x509.Certificate, error) {
+	chains := map[string][]*x509.Certificate{}
+	for _, section := range strings.Split(out, "Alias name: ")[1:] {
+		alias := strings.TrimSpace(strings.SplitN(section, "\n", 2)[0])
+		if !strings.Contains(section, "Entry type: PrivateKeyEntry") {
+			continue
+		}
+
+		var chain []*x509.Certificate
+		rest := []byte(section)
+		for {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("entry (%s): failed to parse certificate: %s", alias, err)
+			}
+			chain = append(chain, cert)
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("private key entry (%s) has no certificate", alias)
+		}
+		chains[strings.ToLower(alias)] = chain
+	}
+	return chains, nil
+}
+
+// readPKCS12Entries reads every private key entry of a PKCS#12 keystore by lowercase alias, unlike readPKCS12,
+// which reads keystores with a single key. The certificate of a key is the one with the same localKeyId.
+func readPKCS12Entries(data []byte, storePassword string) (map[string]PrivateKeyEntry, error) {
+	blocks, err := pkcs12.ToPEM(data, storePassword)
+	if err != nil {
+		return nil, pkcs12Error(err)
+	}
+
+	var certs []*x509.Certificate
+	certsByKeyID := map[string]*x509.Certificate{}
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %s", err)
+		}
+		certs = append(certs, cert)
+		if keyID := block.Headers["localKeyId"]; keyID != "" {
+			certsByKeyID[keyID] = cert
+		}
+	}
+
+	entries := map[string]PrivateKeyEntry{}
+	for _, block := range blocks {
+		if block.Type != "PRIVATE KEY" {
+			continue
+		}
+		alias := block.Headers["friendlyName"]
+		if alias == "" {
+			return nil, fmt.Errorf("%w: private key has no friendly name", ErrUnsupportedType)
+		}
+		key, err := parsePKCS12PEMKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("entry (%s): %s", alias, err)
+		}
+		leaf := certsByKeyID[block.Headers["localKeyId"]]
+		if leaf == nil {
+			return nil, fmt.Errorf("private key entry (%s) has no certificate", alias)
+		}
+		entries[strings.ToLower(alias)] = PrivateKeyEntry{
+			Alias:            alias,
+			PrivateKey:       key,
+			CertificateChain: certificateChain(leaf, certs),
+		}
+	}
+	return entries, nil
+}
+
+// parsePKCS12PEMKey parses the private keys of pkcs12.ToPEM, which are PKCS#1 RSA or SEC 1 EC keys.
+func parsePKCS12PEMKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %s", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,184 @@
+package keystore
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readTestEntry(t *testing.T, pth, alias string) PrivateKeyEntry {
+	ks, err := ReadFile(pth, TypePKCS12, "storepass")
+	require.NoError(t, err)
+	entry, err := ks.PrivateKeyEntry(alias, "")
+	require.NoError(t, err)
+	return entry
+}
+
+func requireKeytool(t *testing.T) {
+	if _, err := exec.LookPath("keytool"); err != nil {
+		t.Skip("keytool is not installed")
+	}
+}
+
+func TestConvertToPKCS12(t *testing.T) {
+	requireKeytool(t)
+
+	rsaEntry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	ecEntry := readTestEntry(t, "testdata/ec.p12", "EC Key")
+
+	tests := []struct {
+		name        string
+		alias       string
+		keyPassword string
+		newPassword string
+		entry       PrivateKeyEntry
+	}{
+		{name: "certificate chain and key password", alias: "upload", keyPassword: "keypass", newPassword: "newpass", entry: rsaEntry},
+		{name: "keeping the password", alias: "ec key", entry: ecEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encodeJKS(PrivateKeyEntry{Alias: tt.alias, PrivateKey: tt.entry.PrivateKey, CertificateChain: tt.entry.CertificateChain}, "storepass", tt.keyPassword)
+			require.NoError(t, err)
+			dir := t.TempDir()
+			pth := filepath.Join(dir, "keystore.jks")
+			require.NoError(t, os.WriteFile(pth, data, 0600))
+
+			outputPth := filepath.Join(dir, "keystore.p12")
+			require.NoError(t, ConvertToPKCS12(pth, TypeJKS, "storepass", tt.alias, tt.keyPassword, outputPth, tt.newPassword))
+
+			password := tt.newPassword
+			if password == "" {
+				password = "storepass"
+			}
+			ks, err := ReadFile(outputPth, TypeUnknown, password)
+			require.NoError(t, err)
+			require.Equal(t, TypePKCS12, ks.Type)
+			require.Equal(t, []string{tt.alias}, ks.Aliases())
+
+			entry, err := ks.PrivateKeyEntry(tt.alias, "")
+			require.NoError(t, err)
+			require.True(t, equalPrivateKeys(tt.entry.PrivateKey, entry.PrivateKey))
+			require.Equal(t, tt.entry.CertificateChain, entry.CertificateChain)
+			require.NoError(t, VerifyConversion(pth, TypeJKS, "storepass", tt.alias, tt.keyPassword, outputPth, tt.newPassword))
+		})
+	}
+}
+
+func TestConvertToPKCS12Validation(t *testing.T) {
+	t.Log("does not convert PKCS12 keystores")
+	{
+		err := ConvertToPKCS12("testdata/rsa-chain.p12", TypePKCS12, "storepass", "upload", "", filepath.Join(t.TempDir(), "keystore.p12"), "")
+		require.EqualError(t, err, "only JKS and JCEKS keystores can be converted to PKCS12, the keystore is: PKCS12")
+	}
+
+	t.Log("requires the alias of a key password other than the store password")
+	{
+		err := ConvertToPKCS12("keystore.jks", TypeJKS, "storepass", "", "keypass", filepath.Join(t.TempDir(), "keystore.p12"), "")
+		require.True(t, errors.Is(err, ErrAliasRequired), err)
+	}
+}
+
+func TestParseKeytoolList(t *testing.T) {
+	rsaEntry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	var chain strings.Builder
+	for i, cert := range rsaEntry.CertificateChain {
+		fmt.Fprintf(&chain, "Certificate[%d]:\n", i+1)
+		chain.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+	root := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rsaEntry.CertificateChain[len(rsaEntry.CertificateChain)-1].Raw})
+
+	out := `Keystore type: JKS
+Keystore provider: SUN
+
+Your keystore contains 2 entries
+
+Alias name: root
+Creation date: Jan 1, 2024
+Entry type: trustedCertEntry
+
+` + string(root) + `
+
+*******************************************
+*******************************************
+
+
+Alias name: Upload
+Creation date: Jan 1, 2024
+Entry type: PrivateKeyEntry
+Certificate chain length: ` + fmt.Sprint(len(rsaEntry.CertificateChain)) + `
+` + chain.String() + `
+
+*******************************************
+*******************************************
+`
+
+	chains, err := parseKeytoolList(out)
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+	require.Equal(t, rsaEntry.CertificateChain, chains["upload"])
+
+	_, err = parseKeytoolList("Alias name: upload\nEntry type: PrivateKeyEntry\n")
+	require.EqualError(t, err, "private key entry (upload) has no certificate")
+}
+
+func TestReadPKCS12Entries(t *testing.T) {
+	rsaEntry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	data, err := os.ReadFile("testdata/rsa-chain.p12")
+	require.NoError(t, err)
+
+	entries, err := readPKCS12Entries(data, "storepass")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "upload", entries["upload"].Alias)
+	require.True(t, equalPrivateKeys(rsaEntry.PrivateKey, entries["upload"].PrivateKey))
+	require.Equal(t, rsaEntry.CertificateChain, entries["upload"].CertificateChain)
+
+	_, err = readPKCS12Entries(data, "wrong")
+	require.True(t, errors.Is(err, ErrWrongStorePassword), err)
+}
+
+func TestVerifyConvertedEntries(t *testing.T) {
+	rsaEntry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	ecEntry := readTestEntry(t, "testdata/ec.p12", "EC Key")
+	source := map[string][]*x509.Certificate{
+		"upload": rsaEntry.CertificateChain,
+		"ec key": ecEntry.CertificateChain,
+	}
+
+	t.Log("accepts the same entries")
+	{
+		converted := map[string]PrivateKeyEntry{"upload": rsaEntry, "ec key": ecEntry}
+		require.NoError(t, verifyConvertedEntries(source, converted))
+	}
+
+	t.Log("rejects missing aliases")
+	{
+		converted := map[string]PrivateKeyEntry{"upload": rsaEntry}
+		err := verifyConvertedEntries(source, converted)
+		require.EqualError(t, err, "aliases of the converted keystore (upload) differ from the aliases of the keystore (ec key, upload)")
+	}
+
+	t.Log("rejects different certificate chains")
+	{
+		converted := map[string]PrivateKeyEntry{"upload": rsaEntry, "ec key": {PrivateKey: ecEntry.PrivateKey, CertificateChain: rsaEntry.CertificateChain}}
+		err := verifyConvertedEntries(source, converted)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "entry (ec key): certificate chain of the converted keystore")
+	}
+
+	t.Log("rejects private keys not belonging to the certificate")
+	{
+		converted := map[string]PrivateKeyEntry{"upload": {PrivateKey: ecEntry.PrivateKey, CertificateChain: rsaEntry.CertificateChain}, "ec key": ecEntry}
+		err := verifyConvertedEntries(source, converted)
+		require.EqualError(t, err, "entry (upload): private key of the converted keystore does not belong to its certificate")
+	}
+}
@@ -0,0 +1,183 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Type is the storage format of a keystore.
+type Type string
+
+// Type values
+const (
+	TypeUnknown Type = ""
+	TypeJKS     Type = "jks"
+	TypeJCEKS   Type = "jceks"
+	TypePKCS12  Type = "pkcs12"
+	TypeBKS     Type = "bks"
+)
+
+// bouncyCastleProviderClass is the security provider keytool, jarsigner and apksigner open BKS keystores with.
+const bouncyCastleProviderClass = "org.bouncycastle.jce.provider.BouncyCastleProvider"
+
+var (
+	jksMagic   = []byte{0xfe, 0xed, 0xfe, 0xed}
+	jceksMagic = []byte{0xce, 0xce, 0xce, 0xce}
+)
+
+// ErrUnknownType is returned when the keystore format can not be recognized from its content.
+var ErrUnknownType = errors.New("unknown keystore format")
+
+// ParseType converts a keystore type input value to a Type.
+func ParseType(s string) (Type, error) {
+	switch t := Type(strings.ToLower(s)); t {
+	case TypeJKS, TypeJCEKS, TypePKCS12, TypeBKS:
+		return t, nil
+	default:
+		return TypeUnknown, fmt.Errorf("unsupported keystore type: %s", s)
+	}
+}
+
+// StoreType returns the keystore type name understood by keytool, jarsigner and apksigner.
+func (t Type) StoreType() string {
+	return strings.ToUpper(string(t))
+}
+
+// Extension returns the conventional file extension of the keystore type.
+func (t Type) Extension() string {
+	switch t {
+	case TypeJKS:
+		return ".jks"
+	case TypeJCEKS:
+		return ".jceks"
+	case TypePKCS12:
+		return ".p12"
+	case TypeBKS:
+		return ".bks"
+	default:
+		return ""
+	}
+}
+
+// ProviderClass returns the security provider class keytool, jarsigner and apksigner need to open the keystore type,
+// empty if the type is supported by the JDK itself.
+// The provider is not shipped with the JDK, its jar has to be installed into the JDK (e.g. on the classpath).
+func (t Type) ProviderClass() string {
+	if t == TypeBKS {
+		return bouncyCastleProviderClass
+	}
+	return ""
+}
+
+// SignerKeyPassword returns the key password to pass to jarsigner and apksigner, empty if they have to use the store password.
+// keytool protects the keys of PKCS#12 keystores with the store password and ignores a different key password,
+// ignored reports if keyPassword is dropped for this reason.
+func SignerKeyPassword(keystoreType Type, storePassword, keyPassword string) (password string, ignored bool) {
+	if keystoreType != TypePKCS12 {
+		return keyPassword, false
+	}
+	return "", keyPassword != "" && keyPassword != storePassword
+}
+
+// DetectType sniffs the keystore format from the magic bytes of the file at pth.
+// Files which are clearly not keystores (empty, HTML, truncated) are rejected with an error,
+// ErrUnknownType is returned for any other unrecognized content.
+func DetectType(pth string) (Type, error) {
+	data, err := os.ReadFile(pth)
+	if err != nil {
+		return TypeUnknown, err
+	}
+	return detectType(data)
+}
+
+func detectType(data []byte) (Type, error) {
+	if len(data) == 0 {
+		return TypeUnknown, errors.New("keystore file is empty")
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		return TypeUnknown, errors.New("keystore file is an HTML or XML document, check that the keystore url points to the keystore itself and not to an error or login page")
+	}
+
+	switch {
+	case bytes.HasPrefix(data, jksMagic):
+		return TypeJKS, checkJavaKeystoreHeader(data, TypeJKS)
+	case bytes.HasPrefix(data, jceksMagic):
+		return TypeJCEKS, checkJavaKeystoreHeader(data, TypeJCEKS)
+	case data[0] == 0x30:
+		return detectPKCS12(data)
+	case isBKS(data):
+		return TypeBKS, nil
+	default:
+		return TypeUnknown, ErrUnknownType
+	}
+}
+
+// checkJavaKeystoreHeader checks the JKS and JCEKS header: magic, version and entry count,
+// followed by at least the trailing SHA-1 integrity digest.
+func checkJavaKeystoreHeader(data []byte, t Type) error {
+	const headerLen = 12
+	if len(data) < headerLen+20 {
+		return fmt.Errorf("keystore file is truncated (%s, %d bytes)", t.StoreType(), len(data))
+	}
+
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version != 1 && version != 2 {
+		return fmt.Errorf("unsupported %s keystore version: %d", t.StoreType(), version)
+	}
+	return nil
+}
+
+// detectPKCS12 checks for the PFX structure: SEQUENCE { INTEGER 3, ... }
+func detectPKCS12(data []byte) (Type, error) {
+	if len(data) < 2 {
+		return TypeUnknown, errors.New("keystore file is truncated")
+	}
+
+	length, offset := int(data[1]), 2
+	if length&0x80 != 0 {
+		lengthBytes := length & 0x7f
+		if lengthBytes == 0 || lengthBytes > 4 {
+			return TypeUnknown, ErrUnknownType
+		}
+		if len(data) < offset+lengthBytes {
+			return TypeUnknown, errors.New("keystore file is truncated")
+		}
+
+		length = 0
+		for _, b := range data[offset : offset+lengthBytes] {
+			length = length<<8 | int(b)
+		}
+		offset += lengthBytes
+	}
+
+	if !bytes.HasPrefix(data[offset:], []byte{0x02, 0x01, 0x03}) {
+		return TypeUnknown, ErrUnknownType
+	}
+	if len(data) < offset+length {
+		return TypeUnknown, fmt.Errorf("keystore file is truncated (PKCS12, %d of %d bytes)", len(data), offset+length)
+	}
+	return TypePKCS12, nil
+}
+
+// isBKS checks the BouncyCastle keystore header: version (1 or 2), salt length, salt, iteration count.
+func isBKS(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+
+	version := binary.BigEndian.Uint32(data[0:4])
+	if version != 1 && version != 2 {
+		return false
+	}
+
+	saltLen := binary.BigEndian.Uint32(data[4:8])
+	if saltLen == 0 || saltLen > 64 {
+		return false
+	}
+	return len(data) >= 8+int(saltLen)+4
+}
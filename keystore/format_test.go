@@ -0,0 +1,84 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectType(t *testing.T) {
+	jks := append([]byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 2, 0, 0, 0, 0}, make([]byte, 20)...)
+	jceks := append([]byte{0xce, 0xce, 0xce, 0xce, 0, 0, 0, 2, 0, 0, 0, 0}, make([]byte, 20)...)
+	pkcs12 := append([]byte{0x30, 0x82, 0x00, 0x05, 0x02, 0x01, 0x03}, make([]byte, 2)...)
+	bks := append([]byte{0, 0, 0, 2, 0, 0, 0, 20}, make([]byte, 24)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Type
+		wantErr string
+	}{
+		{name: "JKS", data: jks, want: TypeJKS},
+		{name: "JCEKS", data: jceks, want: TypeJCEKS},
+		{name: "PKCS12", data: pkcs12, want: TypePKCS12},
+		{name: "BKS", data: bks, want: TypeBKS},
+		{name: "empty", data: []byte{}, wantErr: "empty"},
+		{name: "HTML error page", data: []byte("\n<!DOCTYPE html><html><body>Access Denied</body></html>"), wantErr: "HTML"},
+		{name: "truncated JKS", data: jks[:10], wantErr: "truncated"},
+		{name: "truncated PKCS12", data: pkcs12[:8], wantErr: "truncated"},
+		{name: "invalid JKS version", data: append([]byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 7}, make([]byte, 24)...), wantErr: "version"},
+		{name: "unknown", data: []byte("just some text"), wantErr: ErrUnknownType.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectType(tt.data)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	keystoreType, err := ParseType("PKCS12")
+	require.NoError(t, err)
+	require.Equal(t, TypePKCS12, keystoreType)
+	require.Equal(t, "PKCS12", keystoreType.StoreType())
+
+	_, err = ParseType("automatic")
+	require.Error(t, err)
+
+	keystoreType, err = ParseType("bks")
+	require.NoError(t, err)
+	require.Equal(t, TypeBKS, keystoreType)
+	require.Equal(t, "BKS", keystoreType.StoreType())
+	require.Equal(t, "org.bouncycastle.jce.provider.BouncyCastleProvider", keystoreType.ProviderClass())
+	require.Equal(t, "", TypeJKS.ProviderClass())
+}
+
+func TestSignerKeyPassword(t *testing.T) {
+	tests := []struct {
+		name         string
+		keystoreType Type
+		keyPassword  string
+		want         string
+		wantIgnored  bool
+	}{
+		{name: "JKS key password", keystoreType: TypeJKS, keyPassword: "keypass", want: "keypass"},
+		{name: "unknown type key password", keystoreType: TypeUnknown, keyPassword: "keypass", want: "keypass"},
+		{name: "PKCS12 different key password", keystoreType: TypePKCS12, keyPassword: "keypass", wantIgnored: true},
+		{name: "PKCS12 key password equals store password", keystoreType: TypePKCS12, keyPassword: "storepass"},
+		{name: "PKCS12 empty key password", keystoreType: TypePKCS12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ignored := SignerKeyPassword(tt.keystoreType, "storepass", tt.keyPassword)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.wantIgnored, ignored)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// KeySpec is the algorithm and size of a generated key.
+type KeySpec string
+
+// KeySpec values
+const (
+	KeySpecRSA2048 KeySpec = "rsa_2048"
+	KeySpecRSA4096 KeySpec = "rsa_4096"
+	KeySpecECP256  KeySpec = "ec_p256"
+)
+
+// GenerateOptions describes the private key and the self-signed certificate to generate.
+type GenerateOptions struct {
+	Alias string
+	// DistinguishedName is the subject (and issuer) of the certificate in keytool -dname format, e.g. "CN=Jane Doe, O=Example, C=US".
+	DistinguishedName string
+	KeySpec           KeySpec
+	ValidityDays      int
+}
+
+// Generate creates a private key with a self-signed certificate, as `keytool -genkeypair` does.
+func Generate(opts GenerateOptions) (PrivateKeyEntry, error) {
+	if opts.Alias == "" {
+		return PrivateKeyEntry{}, errors.New("alias is required")
+	}
+	if opts.ValidityDays <= 0 {
+		return PrivateKeyEntry{}, fmt.Errorf("invalid validity: %d days", opts.ValidityDays)
+	}
+	subject, err := ParseDistinguishedName(opts.DistinguishedName)
+	if err != nil {
+		return PrivateKeyEntry{}, err
+	}
+
+	var key crypto.Signer
+	switch opts.KeySpec {
+	case KeySpecRSA2048:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case KeySpecRSA4096:
+		key, err = rsa.GenerateKey(rand.Reader, 4096)
+	case KeySpecECP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return PrivateKeyEntry{}, fmt.Errorf("unsupported key algorithm: %s", opts.KeySpec)
+	}
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to generate key: %s", err)
+	}
+
+	// keytool uses a random 64 bit serial number.
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to generate serial number: %s", err)
+	}
+	notBefore := time.Now().UTC().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      subject,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.AddDate(0, 0, opts.ValidityDays),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+
+	return PrivateKeyEntry{
+		Alias:            opts.Alias,
+		PrivateKey:       key,
+		CertificateChain: []*x509.Certificate{cert},
+	}, nil
+}
+
+// Encode writes the private key entry as a JKS or PKCS#12 keystore.
+// The key of PKCS#12 keystores is protected with the store password, keyPassword is used for JKS keystores only.
+func Encode(keystoreType Type, entry PrivateKeyEntry, storePassword, keyPassword string) ([]byte, error) {
+	if storePassword == "" {
+		return nil, errors.New("keystore password is required")
+	}
+
+	switch keystoreType {
+	case TypePKCS12:
+		return encodePKCS12(entry, storePassword)
+	case TypeJKS:
+		return encodeJKS(entry, storePassword, keyPassword)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, keystoreType.StoreType())
+	}
+}
+
+// ParseDistinguishedName parses a distinguished name in keytool -dname format.
+// Commas in values have to be escaped with a backslash.
+func ParseDistinguishedName(dname string) (pkix.Name, error) {
+	var name pkix.Name
+	for _, rdn := range splitEscaped(dname, ',') {
+		rdn = strings.TrimSpace(rdn)
+		if rdn == "" {
+			continue
+		}
+		i := strings.Index(rdn, "=")
+		if i < 0 {
+			return pkix.Name{}, fmt.Errorf("invalid distinguished name component: %s", rdn)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(rdn[:i])), strings.TrimSpace(rdn[i+1:])
+		if value == "" {
+			return pkix.Name{}, fmt.Errorf("empty value of distinguished name component: %s", key)
+		}
+
+		switch key {
+		case "CN":
+			name.CommonName = value
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		case "ST", "S":
+			name.Province = append(name.Province, value)
+		case "C":
+			name.Country = append(name.Country, value)
+		default:
+			return pkix.Name{}, fmt.Errorf("unsupported distinguished name component: %s, supported components: CN, OU, O, L, ST, C", key)
+		}
+	}
+	if name.CommonName == "" {
+		return pkix.Name{}, errors.New("distinguished name has no common name (CN)")
+	}
+	return name, nil
+}
+
+// splitEscaped splits s at the separators which are not escaped with a backslash, and unescapes the parts.
+func splitEscaped(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
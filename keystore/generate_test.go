@@ -0,0 +1,80 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		keySpec      KeySpec
+		keystoreType Type
+		keyPassword  string
+		algorithm    string
+		size         int
+	}{
+		{keySpec: KeySpecRSA2048, keystoreType: TypePKCS12, algorithm: "RSA", size: 2048},
+		{keySpec: KeySpecECP256, keystoreType: TypePKCS12, algorithm: "EC", size: 256},
+		{keySpec: KeySpecRSA2048, keystoreType: TypeJKS, keyPassword: "keypass", algorithm: "RSA", size: 2048},
+		{keySpec: KeySpecECP256, keystoreType: TypeJKS, algorithm: "EC", size: 256},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.keySpec)+"/"+string(tt.keystoreType), func(t *testing.T) {
+			entry, err := Generate(GenerateOptions{
+				Alias:             "upload",
+				DistinguishedName: `CN=Upload Key, O=Bitrise\, Inc., C=US`,
+				KeySpec:           tt.keySpec,
+				ValidityDays:      10000,
+			})
+			require.NoError(t, err)
+
+			data, err := Encode(tt.keystoreType, entry, "storepass", tt.keyPassword)
+			require.NoError(t, err)
+			pth := filepath.Join(t.TempDir(), "keystore"+tt.keystoreType.Extension())
+			require.NoError(t, os.WriteFile(pth, data, 0600))
+
+			detectedType, err := DetectType(pth)
+			require.NoError(t, err)
+			require.Equal(t, tt.keystoreType, detectedType)
+
+			helper, err := NewHelper(pth, "storepass", "upload", tt.keyPassword, tt.keystoreType)
+			require.NoError(t, err)
+			require.Equal(t, "CN=Upload Key,O=Bitrise\\, Inc.,C=US", helper.CertificateInfo().Subject)
+			require.Equal(t, tt.algorithm, helper.KeyAlgorithm())
+			require.Equal(t, tt.size, helper.KeySize())
+			require.Equal(t, 10000*24*time.Hour, helper.Certificate().NotAfter.Sub(helper.Certificate().NotBefore))
+
+			ks, err := ReadFile(pth, tt.keystoreType, "storepass")
+			require.NoError(t, err)
+			readEntry, err := ks.PrivateKeyEntry("upload", tt.keyPassword)
+			require.NoError(t, err)
+			require.True(t, equalPrivateKeys(entry.PrivateKey, readEntry.PrivateKey))
+		})
+	}
+}
+
+func TestParseDistinguishedName(t *testing.T) {
+	t.Log("parses keytool -dname values")
+	{
+		name, err := ParseDistinguishedName(`CN=Jane Doe, OU=Mobile, O=Example\, Inc., L=Budapest, ST=Pest, C=HU`)
+		require.NoError(t, err)
+		require.Equal(t, "Jane Doe", name.CommonName)
+		require.Equal(t, []string{"Mobile"}, name.OrganizationalUnit)
+		require.Equal(t, []string{"Example, Inc."}, name.Organization)
+		require.Equal(t, []string{"Budapest"}, name.Locality)
+		require.Equal(t, []string{"Pest"}, name.Province)
+		require.Equal(t, []string{"HU"}, name.Country)
+	}
+
+	t.Log("rejects invalid values")
+	{
+		for _, dname := range []string{"", "O=Example", "CN", "CN=", "CN=Jane, EMAIL=jane@example.com"} {
+			_, err := ParseDistinguishedName(dname)
+			require.Error(t, err, dname)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	jks "github.com/pavlo-v-chernykh/keystore-go/v4"
+)
+
+// readJKS reads JKS keystores with keystore-go, JCEKS keystores are left to keytool.
+func readJKS(data []byte, storePassword string) ([]keystoreEntry, error) {
+	if err := checkJavaKeystoreHeader(data, TypeJKS); err != nil {
+		return nil, err
+	}
+
+	ks := jks.New(jks.WithCaseExactAliases())
+	if err := ks.Load(bytes.NewReader(data), []byte(storePassword)); isInvalidDigest(err) {
+		return nil, ErrWrongStorePassword
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid JKS keystore: %s", err)
+	}
+
+	var entries []keystoreEntry
+	for _, alias := range ks.Aliases() {
+		alias := alias
+		if ks.IsTrustedCertificateEntry(alias) {
+			entry, err := ks.GetTrustedCertificateEntry(alias)
+			if err != nil {
+				return nil, fmt.Errorf("entry (%s): %s", alias, err)
+			}
+			certs, err := parseJKSCertificates([]jks.Certificate{entry.Certificate})
+			if err != nil {
+				return nil, fmt.Errorf("entry (%s): %s", alias, err)
+			}
+			entries = append(entries, keystoreEntry{alias: alias, certificates: certs})
+			continue
+		}
+
+		chain, err := ks.GetPrivateKeyEntryCertificateChain(alias)
+		if err != nil {
+			return nil, fmt.Errorf("entry (%s): %s", alias, err)
+		}
+		certs, err := parseJKSCertificates(chain)
+		if err != nil {
+			return nil, fmt.Errorf("entry (%s): %s", alias, err)
+		}
+		entries = append(entries, keystoreEntry{
+			alias:        alias,
+			certificates: certs,
+			decryptKey: func(keyPassword string) (crypto.PrivateKey, error) {
+				if keyPassword == "" {
+					keyPassword = storePassword
+				}
+				entry, err := ks.GetPrivateKeyEntry(alias, []byte(keyPassword))
+				if isInvalidDigest(err) {
+					return nil, ErrWrongKeyPassword
+				} else if err != nil {
+					return nil, err
+				}
+				key, err := parsePKCS8PrivateKey(entry.PrivateKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse private key: %s", err)
+				}
+				return key, nil
+			},
+		})
+	}
+	return entries, nil
+}
+
+// isInvalidDigest reports the integrity check failures of keystore-go, which are caused by a wrong password.
+// keystore-go does not export an error value for them.
+func isInvalidDigest(err error) bool {
+	return err != nil && strings.HasSuffix(err.Error(), "got invalid digest")
+}
+
+func parseJKSCertificates(chain []jks.Certificate) ([]*x509.Certificate, error) {
+	var ders [][]byte
+	for _, cert := range chain {
+		if cert.Type != "X.509" && cert.Type != "X509" {
+			return nil, fmt.Errorf("unsupported certificate type: %s", cert.Type)
+		}
+		ders = append(ders, cert.Content)
+	}
+	return parseCertificates(ders)
+}
+
+// encodeJKS writes a JKS keystore with the private key entry, the key is protected with keyPassword,
+// or with the store password if it is empty.
+func encodeJKS(entry PrivateKeyEntry, storePassword, keyPassword string) ([]byte, error) {
+	if keyPassword == "" {
+		keyPassword = storePassword
+	}
+	key, err := marshalPKCS8PrivateKey(entry.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	var chain []jks.Certificate
+	for _, cert := range entry.CertificateChain {
+		chain = append(chain, jks.Certificate{Type: "X.509", Content: cert.Raw})
+	}
+
+	ks := jks.New()
+	if err := ks.SetPrivateKeyEntry(entry.Alias, jks.PrivateKeyEntry{
+		CreationTime:     time.Now(),
+		PrivateKey:       key,
+		CertificateChain: chain,
+	}, []byte(keyPassword)); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(storePassword)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
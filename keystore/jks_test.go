@@ -0,0 +1,94 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	jks "github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestJKS writes a JKS keystore with a private key entry and a trusted certificate entry.
+func newTestJKS(t *testing.T, storePassword, keyPassword, alias string, entry PrivateKeyEntry) []byte {
+	key, err := marshalPKCS8PrivateKey(entry.PrivateKey)
+	require.NoError(t, err)
+	var chain []jks.Certificate
+	for _, cert := range entry.CertificateChain {
+		chain = append(chain, jks.Certificate{Type: "X.509", Content: cert.Raw})
+	}
+
+	ks := jks.New()
+	require.NoError(t, ks.SetPrivateKeyEntry(alias, jks.PrivateKeyEntry{CreationTime: time.Now(), PrivateKey: key, CertificateChain: chain}, []byte(keyPassword)))
+	require.NoError(t, ks.SetTrustedCertificateEntry("ca", jks.TrustedCertificateEntry{CreationTime: time.Now(), Certificate: chain[len(chain)-1]}))
+
+	var buf bytes.Buffer
+	require.NoError(t, ks.Store(&buf, []byte(storePassword)))
+	return buf.Bytes()
+}
+
+func TestReadJKS(t *testing.T) {
+	p12, err := ReadFile("testdata/rsa-chain.p12", TypePKCS12, "storepass")
+	require.NoError(t, err)
+	p12Entry, err := p12.PrivateKeyEntry("upload", "")
+	require.NoError(t, err)
+
+	data := newTestJKS(t, "storepass", "keypass", "upload", p12Entry)
+
+	t.Log("reads the private key entry")
+	{
+		ks, err := Read(data, TypeUnknown, "storepass")
+		require.NoError(t, err)
+		require.Equal(t, TypeJKS, ks.Type)
+		require.Equal(t, []string{"upload"}, ks.Aliases())
+
+		entry, err := ks.PrivateKeyEntry("UPLOAD", "keypass")
+		require.NoError(t, err)
+		require.Equal(t, p12Entry.PrivateKey, entry.PrivateKey)
+		require.Equal(t, p12Entry.CertificateChain, entry.CertificateChain)
+		require.Equal(t, "RSA", entry.KeyAlgorithm())
+		require.Equal(t, 2048, entry.KeySize())
+	}
+
+	t.Log("returns distinct errors for wrong passwords and missing aliases")
+	{
+		_, err := Read(data, TypeJKS, "wrong")
+		require.True(t, errors.Is(err, ErrWrongStorePassword))
+
+		ks, err := Read(data, TypeJKS, "storepass")
+		require.NoError(t, err)
+
+		_, err = ks.PrivateKeyEntry("upload", "wrong")
+		require.True(t, errors.Is(err, ErrWrongKeyPassword))
+
+		_, err = ks.PrivateKeyEntry("upload", "")
+		require.True(t, errors.Is(err, ErrWrongKeyPassword))
+
+		_, err = ks.PrivateKeyEntry("ca", "keypass")
+		require.True(t, errors.Is(err, ErrAliasNotFound))
+
+		_, err = ks.PrivateKeyEntry("release", "keypass")
+		require.True(t, errors.Is(err, ErrAliasNotFound))
+	}
+
+	t.Log("rejects truncated keystores")
+	{
+		_, err := Read(data[:100], TypeJKS, "storepass")
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrWrongStorePassword))
+	}
+
+	t.Log("writes keystores read back with the key password")
+	{
+		encoded, err := Encode(TypeJKS, p12Entry, "storepass", "keypass")
+		require.NoError(t, err)
+
+		ks, err := Read(encoded, TypeUnknown, "storepass")
+		require.NoError(t, err)
+		entry, err := ks.PrivateKeyEntry("upload", "keypass")
+		require.NoError(t, err)
+		require.True(t, equalPrivateKeys(p12Entry.PrivateKey, entry.PrivateKey))
+		require.Equal(t, p12Entry.CertificateChain, entry.CertificateChain)
+	}
+}
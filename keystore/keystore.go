@@ -3,8 +3,9 @@ package keystore
 // https://github.com/calabash/calabash-android/blob/6bb3d9ac9eadf353dc7573c28a957e88e6669f67/ruby-gem/lib/calabash-android/helpers.rb
 
 import (
-	"bufio"
 	"bytes"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -18,12 +19,18 @@ import (
 
 const jarsigner = "/usr/bin/jarsigner"
 
+// digestAlgorithm is the jarsigner digest algorithm of the signed files.
+const digestAlgorithm = "SHA-256"
+
 // Helper ...
 type Helper struct {
 	keystorePth        string
 	keystorePassword   string
+	keystoreType       Type
 	alias              string
 	signatureAlgorithm string
+	digestAlgorithm    string
+	certificates       []*x509.Certificate
 }
 
 // Execute ...
@@ -61,17 +68,93 @@ func ExecuteForOutput(cmdSlice []string) (string, error) {
 }
 
 // NewHelper ...
-func NewHelper(keystorePth, keystorePassword, alias string) (Helper, error) {
+// Keystores are read with Read, the certificate of the key is read with keytool if Read does not support the keystore.
+// If alias is empty, the only private key entry of a natively read keystore is used.
+// keystoreType is passed as -storetype to keytool and jarsigner, TypeUnknown lets them guess the format.
+func NewHelper(keystorePth, keystorePassword, alias, keyPassword string, keystoreType Type) (Helper, error) {
 	if exist, err := pathutil.IsPathExists(keystorePth); err != nil {
 		return Helper{}, err
 	} else if !exist {
 		return Helper{}, fmt.Errorf("keystore not exist at: %s", keystorePth)
 	}
 
+	var certificates []*x509.Certificate
+	ks, err := ReadFile(keystorePth, keystoreType, keystorePassword)
+	switch {
+	case err == nil:
+		if alias == "" {
+			if alias, err = defaultAlias(ks); err != nil {
+				return Helper{}, err
+			}
+			log.Printf("Using the only private key entry of the keystore: %s", alias)
+		}
+		entry, err := ks.PrivateKeyEntry(alias, keyPassword)
+		if errors.Is(err, ErrAliasNotFound) {
+			return Helper{}, aliasNotFoundError(err, alias, ks.Aliases())
+		} else if err != nil {
+			return Helper{}, err
+		}
+		alias = entry.Alias
+		certificates = entry.CertificateChain
+	case errors.Is(err, ErrUnsupportedType) || errors.Is(err, ErrUnknownType):
+		if alias == "" {
+			return Helper{}, fmt.Errorf("%w to read the certificate with keytool, as %s", ErrAliasRequired, err)
+		}
+		log.Printf("Reading the certificate with keytool, as %s", err)
+		if certificates, err = exportCertificate(keystorePth, keystorePassword, alias, keystoreType); err != nil {
+			return Helper{}, err
+		}
+	default:
+		return Helper{}, err
+	}
+
+	signatureAlgorithm, err := signatureAlgorithmForKey(certificates[0].PublicKey)
+	if err != nil {
+		return Helper{}, err
+	}
+
+	return Helper{
+		keystorePth:        keystorePth,
+		keystorePassword:   keystorePassword,
+		keystoreType:       keystoreType,
+		alias:              alias,
+		signatureAlgorithm: signatureAlgorithm,
+		digestAlgorithm:    digestAlgorithm,
+		certificates:       certificates,
+	}, nil
+}
+
+// Alias returns the alias of the signing key, as stored in the keystore.
+func (helper Helper) Alias() string {
+	return helper.alias
+}
+
+// Certificate returns the certificate of the signing key.
+func (helper Helper) Certificate() *x509.Certificate {
+	return helper.certificates[0]
+}
+
+// CertificateChain returns the certificate chain of the signing key, starting with its certificate.
+func (helper Helper) CertificateChain() []*x509.Certificate {
+	return helper.certificates
+}
+
+// KeyAlgorithm returns the algorithm of the signing key as named by Java: RSA, EC, DSA or Ed25519.
+func (helper Helper) KeyAlgorithm() string {
+	return publicKeyAlgorithm(helper.Certificate().PublicKey)
+}
+
+// KeySize returns the size of the signing key in bits.
+func (helper Helper) KeySize() int {
+	return publicKeySize(helper.Certificate().PublicKey)
+}
+
+// exportCertificate reads the certificate of alias with keytool, for keystore types not supported by the native reader.
+func exportCertificate(keystorePth, keystorePassword, alias string, keystoreType Type) ([]*x509.Certificate, error) {
 	cmdSlice := []string{
 		"keytool",
-		"-list",
-		"-v",
+		"-exportcert",
+		"-rfc",
 
 		"-keystore",
 		keystorePth,
@@ -85,49 +168,52 @@ func NewHelper(keystorePth, keystorePassword, alias string) (Helper, error) {
 		"-J-Duser.language=en-US",
 	}
 
-	out, err := ExecuteForOutput(cmdSlice)
-	if err != nil {
-		return Helper{}, properError(err, out)
+	if keystoreType != TypeUnknown {
+		cmdSlice = append(cmdSlice, "-storetype", keystoreType.StoreType())
 	}
-	if out == "" {
-		return Helper{}, fmt.Errorf("failed to read keystore, maybe alias (%s) or password (%s) is not correct", alias, "****")
+	if providerClass := keystoreType.ProviderClass(); providerClass != "" {
+		cmdSlice = append(cmdSlice, "-providerclass", providerClass)
 	}
 
-	signatureAlgorithm, err := findSignatureAlgorithm(out)
+	out, err := ExecuteForOutput(cmdSlice)
 	if err != nil {
-		return Helper{}, err
-	}
-	if signatureAlgorithm == "" {
-		return Helper{}, errors.New("failed to find signature algorithm")
+		return nil, properError(err, out)
 	}
 
-	return Helper{
-		keystorePth:        keystorePth,
-		keystorePassword:   keystorePassword,
-		alias:              alias,
-		signatureAlgorithm: signatureAlgorithm,
-	}, nil
+	// keytool may print warnings before the certificate, pem.Decode skips them.
+	block, _ := pem.Decode([]byte(out))
+	if block == nil {
+		return nil, fmt.Errorf("failed to read keystore, maybe alias (%s) or password (%s) is not correct", alias, "****")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+	return []*x509.Certificate{cert}, nil
 }
 
-func (helper Helper) createSignCmd(buildArtifactPth, destBuildArtifactPth, privateKeyPassword string) ([]string, error) {
-	split := strings.Split(helper.signatureAlgorithm, "with")
-	if len(split) != 2 {
-		return []string{}, fmt.Errorf("failed to parse signature algorithm: %s", helper.signatureAlgorithm)
+// signatureAlgorithmForKey returns the jarsigner signature algorithm matching the key of the certificate.
+func signatureAlgorithmForKey(publicKey interface{}) (string, error) {
+	switch algorithm := publicKeyAlgorithm(publicKey); algorithm {
+	case "RSA", "DSA":
+		return "SHA256with" + algorithm, nil
+	case "EC":
+		return "SHA256withECDSA", nil
+	default:
+		return "", fmt.Errorf("unsupported signing key algorithm: %s", algorithm)
 	}
-	split = strings.Split(split[1], "and")
-
-	signingAlgorithm := "SHA256with" + split[0]
-	digestAlgorithm := "SHA-256"
+}
 
+func (helper Helper) createSignCmd(buildArtifactPth, destBuildArtifactPth, privateKeyPassword string) []string {
 	cmdSlice := []string{
 		jarsigner,
 		"-sigfile",
 		"CERT",
 
 		"-sigalg",
-		signingAlgorithm,
+		helper.signatureAlgorithm,
 		"-digestalg",
-		digestAlgorithm,
+		helper.digestAlgorithm,
 
 		"-keystore",
 		helper.keystorePth,
@@ -135,13 +221,20 @@ func (helper Helper) createSignCmd(buildArtifactPth, destBuildArtifactPth, priva
 		helper.keystorePassword,
 	}
 
-	if privateKeyPassword != "" {
-		cmdSlice = append(cmdSlice, "-keypass", privateKeyPassword)
+	if helper.keystoreType != TypeUnknown {
+		cmdSlice = append(cmdSlice, "-storetype", helper.keystoreType.StoreType())
+	}
+	if providerClass := helper.keystoreType.ProviderClass(); providerClass != "" {
+		cmdSlice = append(cmdSlice, "-providerclass", providerClass)
+	}
+
+	if keyPassword, _ := SignerKeyPassword(helper.keystoreType, helper.keystorePassword, privateKeyPassword); keyPassword != "" {
+		cmdSlice = append(cmdSlice, "-keypass", keyPassword)
 	}
 
 	cmdSlice = append(cmdSlice, "-signedjar", destBuildArtifactPth, buildArtifactPth, helper.alias)
 
-	return cmdSlice, nil
+	return cmdSlice
 }
 
 // SignBuildArtifact ...
@@ -152,10 +245,7 @@ func (helper Helper) SignBuildArtifact(buildArtifactPth, destBuildArtifactPth, p
 		return fmt.Errorf("Build Artifact not exist at: %s", buildArtifactPth)
 	}
 
-	cmdSlice, err := helper.createSignCmd(buildArtifactPth, destBuildArtifactPth, privateKeyPassword)
-	if err != nil {
-		return err
-	}
+	cmdSlice := helper.createSignCmd(buildArtifactPth, destBuildArtifactPth, privateKeyPassword)
 
 	prinatableCmd := command.PrintableCommandArgs(false, secureSignCmd(cmdSlice))
 	log.Printf("=> %s", prinatableCmd)
@@ -200,39 +290,6 @@ func properError(err error, out string) error {
 	return err
 }
 
-func findSignatureAlgorithm(keystoreData string) (string, error) {
-	scanner := bufio.NewScanner(strings.NewReader(keystoreData))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.Contains(line, "Signature algorithm name: ") {
-			split := strings.Split(line, "Signature algorithm name: ")
-
-			if len(split) < 2 {
-				return "", fmt.Errorf("failed to expand signature algorithm from: %s", line)
-			}
-
-			alg := split[1]
-			split = strings.Split(alg, " ")
-
-			if len(split) > 1 {
-				log.Warnf("🚨 Signature algorithm name contains unnecessary postfix: %s", alg)
-				log.Printf("Trimmed signature algorithm name: %s", split[0])
-
-				alg = split[0]
-			}
-			return alg, nil
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", err
-	}
-
-	return "", nil
-}
-
 func secureSignCmd(cmdSlice []string) []string {
 	securedCmdSlice := []string{}
 	secureNextParam := false
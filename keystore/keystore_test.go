@@ -1,6 +1,7 @@
 package keystore
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -16,17 +17,16 @@ func TestCreateSignCmd(t *testing.T) {
 		keystorePassword := "pass"
 		alias := "alias"
 		keypassword := "keypass"
-		signatureAlgorithm := "SHA256withRSA"
 
 		keystore := Helper{
 			keystorePth:        keystorePath,
 			keystorePassword:   keystorePassword,
 			alias:              alias,
-			signatureAlgorithm: signatureAlgorithm,
+			signatureAlgorithm: "SHA256withRSA",
+			digestAlgorithm:    "SHA-256",
 		}
 
-		cmdSlice, err := keystore.createSignCmd(apkPth, destApkPth, keypassword)
-		require.NoError(t, err)
+		cmdSlice := keystore.createSignCmd(apkPth, destApkPth, keypassword)
 		require.Equal(t, 17, len(cmdSlice))
 
 		actual := strings.Join(cmdSlice, " ")
@@ -34,7 +34,7 @@ func TestCreateSignCmd(t *testing.T) {
 		require.Equal(t, expected, actual)
 	}
 
-	t.Log("signature algorithm: MD5withRSA")
+	t.Log("signature algorithm: SHA256withECDSA")
 	{
 		apkPth := "android.apk"
 		destApkPth := "android-signed.apk"
@@ -42,68 +42,94 @@ func TestCreateSignCmd(t *testing.T) {
 		keystorePassword := "pass"
 		alias := "alias"
 		keypassword := "keypass"
-		signatureAlgorithm := "MD5withRSA"
 
 		keystore := Helper{
 			keystorePth:        keystorePath,
 			keystorePassword:   keystorePassword,
 			alias:              alias,
-			signatureAlgorithm: signatureAlgorithm,
+			signatureAlgorithm: "SHA256withECDSA",
+			digestAlgorithm:    "SHA-256",
 		}
 
-		cmdSlice, err := keystore.createSignCmd(apkPth, destApkPth, keypassword)
-		require.NoError(t, err)
+		cmdSlice := keystore.createSignCmd(apkPth, destApkPth, keypassword)
 		require.Equal(t, 17, len(cmdSlice))
 
 		actual := strings.Join(cmdSlice, " ")
-		expected := jarsigner + " -sigfile CERT -sigalg SHA256withRSA -digestalg SHA-256 -keystore keystore.jks -storepass pass -keypass keypass -signedjar android-signed.apk android.apk alias"
+		expected := jarsigner + " -sigfile CERT -sigalg SHA256withECDSA -digestalg SHA-256 -keystore keystore.jks -storepass pass -keypass keypass -signedjar android-signed.apk android.apk alias"
 		require.Equal(t, expected, actual)
 	}
+}
+
+func TestCreateSignCmdWithKeystoreType(t *testing.T) {
+	keystore := Helper{
+		keystorePth:        "keystore.p12",
+		keystorePassword:   "pass",
+		keystoreType:       TypePKCS12,
+		alias:              "alias",
+		signatureAlgorithm: "SHA256withRSA",
+		digestAlgorithm:    "SHA-256",
+	}
+
+	cmdSlice := keystore.createSignCmd("android.apk", "android-signed.apk", "")
+
+	actual := strings.Join(cmdSlice, " ")
+	expected := jarsigner + " -sigfile CERT -sigalg SHA256withRSA -digestalg SHA-256 -keystore keystore.p12 -storepass pass -storetype PKCS12 -signedjar android-signed.apk android.apk alias"
+	require.Equal(t, expected, actual)
 
-	t.Log("signature algorithm: MD5withRSAandMGF1")
+	t.Log("key password is not passed for PKCS12 keystores")
 	{
-		apkPth := "android.apk"
-		destApkPth := "android-signed.apk"
-		keystorePath := "keystore.jks"
-		keystorePassword := "pass"
-		alias := "alias"
-		keypassword := "keypass"
-		signatureAlgorithm := "MD5withRSAandMGF1"
+		cmdSlice := keystore.createSignCmd("android.apk", "android-signed.apk", "keypass")
+		require.Equal(t, expected, strings.Join(cmdSlice, " "))
+	}
 
-		keystore := Helper{
-			keystorePth:        keystorePath,
-			keystorePassword:   keystorePassword,
-			alias:              alias,
-			signatureAlgorithm: signatureAlgorithm,
-		}
+	t.Log("BKS keystores are opened with the Bouncy Castle provider")
+	{
+		keystore.keystorePth = "keystore.bks"
+		keystore.keystoreType = TypeBKS
+		cmdSlice := keystore.createSignCmd("android.apk", "android-signed.apk", "keypass")
+		expected := jarsigner + " -sigfile CERT -sigalg SHA256withRSA -digestalg SHA-256 -keystore keystore.bks -storepass pass -storetype BKS -providerclass org.bouncycastle.jce.provider.BouncyCastleProvider -keypass keypass -signedjar android-signed.apk android.apk alias"
+		require.Equal(t, expected, strings.Join(cmdSlice, " "))
+	}
+}
 
-		cmdSlice, err := keystore.createSignCmd(apkPth, destApkPth, keypassword)
+func TestNewHelper(t *testing.T) {
+	t.Log("reads the certificate and derives the signature algorithm from the key")
+	{
+		helper, err := NewHelper("testdata/rsa-chain.p12", "storepass", "upload", "", TypeUnknown)
 		require.NoError(t, err)
-		require.Equal(t, 17, len(cmdSlice))
+		require.Equal(t, "SHA256withRSA", helper.signatureAlgorithm)
+		require.Equal(t, "SHA-256", helper.digestAlgorithm)
+		require.Equal(t, "CN=Upload Key,O=Bitrise", helper.Certificate().Subject.String())
+		require.Equal(t, 2, len(helper.CertificateChain()))
+		require.Equal(t, "RSA", helper.KeyAlgorithm())
+		require.Equal(t, 2048, helper.KeySize())
 
-		actual := strings.Join(cmdSlice, " ")
-		expected := jarsigner + " -sigfile CERT -sigalg SHA256withRSA -digestalg SHA-256 -keystore keystore.jks -storepass pass -keypass keypass -signedjar android-signed.apk android.apk alias"
-		require.Equal(t, expected, actual)
+		helper, err = NewHelper("testdata/ec.p12", "storepass", "EC Key", "", TypePKCS12)
+		require.NoError(t, err)
+		require.Equal(t, "SHA256withECDSA", helper.signatureAlgorithm)
 	}
-}
 
-func TestFindSignatureAlgorithm(t *testing.T) {
-	keystoreData := `Alias name: MyAndroidKey
-Creation date: Jun 2, 2016
-Entry type: PrivateKeyEntry
-Certificate chain length: 1
-Certificate[1]:
-Owner: CN=Bitrise, OU=Mobile Development, O=MyCompany, L=Budapest, ST=Pest, C=HU
-Issuer: CN=Bitrise, OU=Mobile Development, O=MyCompany, L=Budapest, ST=Pest, C=HU
-Serial number: 5750111
-Valid from: Thu Jun 02 19:56:20 CEST 2016 until: Mon May 27 19:56:20 CEST 2041
-Certificate fingerprints:
-	 MD5:  CA:30:61:CB:AD:70:03:73:C7:FD:91:A4:9C:FB:92:F9
-	 SHA1: 66:C3:60:5B:B8:0B:B0:2C:AE:C5:54:72:B6:B2:D6:18:99:FB:70:9F
-	 Signature algorithm name: SHA256withRSA
-	 Version: 3
-`
-	signatureAlgorithm, err := findSignatureAlgorithm(keystoreData)
-	require.NoError(t, err)
-	require.Equal(t, "SHA256withRSA", signatureAlgorithm)
+	t.Log("returns distinct errors for wrong passwords and missing aliases")
+	{
+		_, err := NewHelper("testdata/rsa-chain.p12", "wrong", "upload", "", TypeUnknown)
+		require.True(t, errors.Is(err, ErrWrongStorePassword))
+
+		_, err = NewHelper("testdata/rsa-chain.p12", "storepass", "upload", "wrong", TypeUnknown)
+		require.True(t, errors.Is(err, ErrWrongKeyPassword))
+
+		_, err = NewHelper("testdata/rsa-chain.p12", "storepass", "release", "", TypeUnknown)
+		require.True(t, errors.Is(err, ErrAliasNotFound))
+		require.Contains(t, err.Error(), "aliases in the keystore: upload (closest match: upload)")
+	}
+
+	t.Log("selects the only private key entry if the alias is empty")
+	{
+		helper, err := NewHelper("testdata/ec.p12", "storepass", "", "", TypeUnknown)
+		require.NoError(t, err)
+		require.Equal(t, "EC Key", helper.Alias())
+
+		helper, err = NewHelper("testdata/ec.p12", "storepass", "ec key", "", TypeUnknown)
+		require.NoError(t, err)
+		require.Equal(t, "EC Key", helper.Alias())
+	}
 }
@@ -0,0 +1,139 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/youmark/pkcs8"
+)
+
+// ParseKeyCertificate reads a private key and its certificate chain, as passed to apksigner with --key and --cert.
+// The key is a PKCS#8 (optionally encrypted with keyPassword), PKCS#1 RSA or SEC 1 EC key, the certificates are X.509 certificates,
+// both in PEM or DER format. The chain is built from the certificate matching the key.
+func ParseKeyCertificate(alias string, keyData, certificateData []byte, keyPassword string) (PrivateKeyEntry, error) {
+	key, err := parsePrivateKey(keyData, keyPassword)
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	certs, err := parseCertificateFile(certificateData)
+	if err != nil {
+		return PrivateKeyEntry{}, fmt.Errorf("failed to parse certificate: %s", err)
+	}
+
+	for _, cert := range certs {
+		if matchesPublicKey(key, cert.PublicKey) {
+			return PrivateKeyEntry{
+				Alias:            alias,
+				PrivateKey:       key,
+				CertificateChain: certificateChain(cert, certs),
+			}, nil
+		}
+	}
+	return PrivateKeyEntry{}, errors.New("none of the certificates belongs to the private key")
+}
+
+// MarshalPKCS8PrivateKey returns the unencrypted PKCS#8 DER form of the key, as read by apksigner --key.
+func MarshalPKCS8PrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	return marshalPKCS8PrivateKey(key)
+}
+
+func parsePrivateKey(data []byte, password string) (crypto.PrivateKey, error) {
+	blockType, der := "", data
+	if block, _ := pem.Decode(data); block != nil {
+		if _, ok := block.Headers["Proc-Type"]; ok {
+			return nil, errors.New("legacy PEM encryption is not supported, convert the key to PKCS#8 (openssl pkcs8 -topk8)")
+		}
+		blockType, der = block.Type, block.Bytes
+	}
+
+	switch blockType {
+	case "ENCRYPTED PRIVATE KEY":
+		return decryptPKCS8PrivateKey(der, password)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		return parsePKCS8PrivateKey(der)
+	case "":
+		// DER keys are tried in all formats.
+		if key, err := parsePKCS8PrivateKey(der); err == nil {
+			return key, nil
+		}
+		if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+			return key, nil
+		}
+		if key, err := x509.ParseECPrivateKey(der); err == nil {
+			return key, nil
+		}
+		if password != "" {
+			return decryptPKCS8PrivateKey(der, password)
+		}
+		return nil, errors.New("unknown key format, expected a PKCS#8, PKCS#1 or SEC 1 key in PEM or DER format")
+	default:
+		return nil, fmt.Errorf("unexpected PEM block: %s", blockType)
+	}
+}
+
+// decryptPKCS8PrivateKey decrypts a PBES2 encrypted PKCS#8 key, the default of openssl pkcs8 -topk8.
+func decryptPKCS8PrivateKey(der []byte, password string) (crypto.PrivateKey, error) {
+	if password == "" {
+		return nil, errors.New("private key is encrypted, the key password is required")
+	}
+	key, err := pkcs8.ParsePKCS8PrivateKey(der, []byte(password))
+	if err != nil && err.Error() == "pkcs8: incorrect password" {
+		// youmark/pkcs8 does not export an error value for wrong passwords.
+		return nil, ErrWrongKeyPassword
+	}
+	return key, err
+}
+
+// parseCertificateFile parses the CERTIFICATE blocks of a PEM file, or the concatenated certificates of a DER file.
+func parseCertificateFile(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	certs, err := x509.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+func matchesPublicKey(key crypto.PrivateKey, publicKey crypto.PublicKey) bool {
+	if dsaKey, ok := key.(*dsa.PrivateKey); ok {
+		other, ok := publicKey.(*dsa.PublicKey)
+		return ok && dsaKey.Y.Cmp(other.Y) == 0 && dsaKey.P.Cmp(other.P) == 0 && dsaKey.Q.Cmp(other.Q) == 0 && dsaKey.G.Cmp(other.G) == 0
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false
+	}
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && public.Equal(publicKey)
+}
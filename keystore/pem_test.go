@@ -0,0 +1,66 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	youmarkpkcs8 "github.com/youmark/pkcs8"
+)
+
+func TestParseKeyCertificate(t *testing.T) {
+	rsaEntry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	ecEntry := readTestEntry(t, "testdata/ec.p12", "EC Key")
+
+	pkcs8, err := marshalPKCS8PrivateKey(rsaEntry.PrivateKey)
+	require.NoError(t, err)
+	encryptedPKCS8, err := youmarkpkcs8.MarshalPrivateKey(rsaEntry.PrivateKey, []byte("keypass"), nil)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecEntry.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	// The chain is built from the certificate of the key, regardless of the order in the file.
+	var rsaChainPEM []byte
+	for i := len(rsaEntry.CertificateChain) - 1; i >= 0; i-- {
+		rsaChainPEM = append(rsaChainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rsaEntry.CertificateChain[i].Raw})...)
+	}
+
+	tests := []struct {
+		name        string
+		key         []byte
+		certificate []byte
+		password    string
+		entry       PrivateKeyEntry
+	}{
+		{name: "PKCS#8 PEM", key: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), certificate: rsaChainPEM, entry: rsaEntry},
+		{name: "PKCS#8 DER", key: pkcs8, certificate: rsaChainPEM, entry: rsaEntry},
+		{name: "encrypted PKCS#8 PEM", key: pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedPKCS8}), certificate: rsaChainPEM, password: "keypass", entry: rsaEntry},
+		{name: "PKCS#1 PEM", key: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaEntry.PrivateKey.(*rsa.PrivateKey))}), certificate: rsaChainPEM, entry: rsaEntry},
+		{name: "SEC 1 PEM with DER certificate", key: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}), certificate: ecEntry.Certificate().Raw, entry: ecEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseKeyCertificate("signing", tt.key, tt.certificate, tt.password)
+			require.NoError(t, err)
+			require.Equal(t, "signing", entry.Alias)
+			require.True(t, equalPrivateKeys(tt.entry.PrivateKey, entry.PrivateKey))
+			require.Equal(t, tt.entry.CertificateChain, entry.CertificateChain)
+		})
+	}
+
+	t.Log("fails with a wrong key password")
+	{
+		_, err := ParseKeyCertificate("signing", pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedPKCS8}), rsaChainPEM, "wrong")
+		require.True(t, errors.Is(err, ErrWrongKeyPassword), err)
+	}
+
+	t.Log("fails if the certificate does not belong to the key")
+	{
+		_, err := ParseKeyCertificate("signing", pkcs8, ecEntry.Certificate().Raw, "")
+		require.EqualError(t, err, "none of the certificates belongs to the private key")
+	}
+}
@@ -0,0 +1,157 @@
+package keystore
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Entries of the output of the PEPK tool with --rsa-aes-encryption, as uploaded to Google Play.
+const (
+	PEPKEncryptedKeyEntry = "encryptedPrivateKey"
+	PEPKCertificateEntry  = "certificate.pem"
+)
+
+const (
+	pepkAESKeySize = 32
+
+	aesKeyWrapBlockSize = 8
+	// aesKeyWrapPadIVPrefix is the constant half of the alternative initial value of RFC 5649.
+	aesKeyWrapPadIVPrefix = 0xA65959A6
+	aesKeyWrapRounds      = 6
+)
+
+// ExportEncryptedKey encrypts the private key of the entry to the encryption public key of Google Play,
+// the way the PEPK tool does with --rsa-aes-encryption: a random AES-256 key is encrypted with RSA-OAEP (SHA-256),
+// and the PKCS#8 private key is wrapped with the AES key (AES key wrap with padding, RFC 5649).
+// The result is a zip holding the encrypted key and, if includeCertificate is set, the certificate of the key in PEM format.
+func ExportEncryptedKey(entry PrivateKeyEntry, encryptionPublicKey []byte, includeCertificate bool) ([]byte, error) {
+	publicKey, err := ParseEncryptionPublicKey(encryptionPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := encryptPrivateKey(entry.PrivateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{PEPKEncryptedKeyEntry: encryptedKey}
+	if includeCertificate {
+		files[PEPKCertificateEntry] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: entry.Certificate().Raw})
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{PEPKEncryptedKeyEntry, PEPKCertificateEntry} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseEncryptionPublicKey parses the RSA encryption public key provided by Google Play, in PEM or DER format.
+func ParseEncryptionPublicKey(data []byte) (*rsa.PublicKey, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse encryption public key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("encryption public key is not an RSA key: %s", publicKeyAlgorithm(key))
+	}
+	return rsaKey, nil
+}
+
+func encryptPrivateKey(key crypto.PrivateKey, publicKey *rsa.PublicKey) ([]byte, error) {
+	plainKey, err := marshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	aesKey, err := randomBytes(pepkAESKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedAESKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, aesKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt AES key: %s", err)
+	}
+	wrappedKey, err := aesKeyWrapPad(aesKey, plainKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap private key: %s", err)
+	}
+	return append(encryptedAESKey, wrappedKey...), nil
+}
+
+// aesKeyWrapPad wraps plaintext with kek using AES key wrap with padding (RFC 5649).
+func aesKeyWrapPad(kek, plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, errors.New("empty plaintext")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aesKeyWrapBlockSize)
+	binary.BigEndian.PutUint32(iv, aesKeyWrapPadIVPrefix)
+	binary.BigEndian.PutUint32(iv[4:], uint32(len(plaintext)))
+	padded := make([]byte, (len(plaintext)+aesKeyWrapBlockSize-1)/aesKeyWrapBlockSize*aesKeyWrapBlockSize)
+	copy(padded, plaintext)
+
+	if len(padded) == aesKeyWrapBlockSize {
+		out := make([]byte, aes.BlockSize)
+		block.Encrypt(out, append(iv, padded...))
+		return out, nil
+	}
+
+	// The wrapping process of RFC 3394, section 2.2.1, with the alternative initial value.
+	n := len(padded) / aesKeyWrapBlockSize
+	a := iv
+	r := padded
+	b := make([]byte, aes.BlockSize)
+	for j := 0; j < aesKeyWrapRounds; j++ {
+		for i := 0; i < n; i++ {
+			ri := r[i*aesKeyWrapBlockSize : (i+1)*aesKeyWrapBlockSize]
+			block.Encrypt(b, append(append([]byte{}, a...), ri...))
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:aesKeyWrapBlockSize])^t)
+			copy(ri, b[aesKeyWrapBlockSize:])
+		}
+	}
+	return append(a, r...), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %s", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,99 @@
+package keystore
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESKeyWrapPad(t *testing.T) {
+	// Test vectors of RFC 5649, section 6.
+	kek, err := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	require.NoError(t, err)
+
+	tests := []struct {
+		plaintext string
+		want      string
+	}{
+		{plaintext: "c37b7e6492584340bed12207808941155068f738", want: "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a"},
+		{plaintext: "466f7250617369", want: "afbeb0f07dfbf5419200f2ccb50bb24f"},
+	}
+	for _, tt := range tests {
+		plaintext, err := hex.DecodeString(tt.plaintext)
+		require.NoError(t, err)
+
+		wrapped, err := aesKeyWrapPad(kek, plaintext)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, hex.EncodeToString(wrapped))
+	}
+}
+
+func TestExportEncryptedKey(t *testing.T) {
+	entry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+
+	encryptionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&encryptionKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+
+	for _, includeCertificate := range []bool{true, false} {
+		exported, err := ExportEncryptedKey(entry, publicKeyPEM, includeCertificate)
+		require.NoError(t, err)
+
+		files := readTestZip(t, exported)
+		encrypted := files[PEPKEncryptedKeyEntry]
+		require.True(t, len(encrypted) > encryptionKey.Size())
+
+		// The AES key is decrypted with the encryption key, the private key is wrapped with the AES key.
+		aesKey, err := rsa.DecryptOAEP(sha256.New(), nil, encryptionKey, encrypted[:encryptionKey.Size()], nil)
+		require.NoError(t, err)
+		require.Equal(t, pepkAESKeySize, len(aesKey))
+		plainKey, err := marshalPKCS8PrivateKey(entry.PrivateKey)
+		require.NoError(t, err)
+		wrapped, err := aesKeyWrapPad(aesKey, plainKey)
+		require.NoError(t, err)
+		require.Equal(t, wrapped, encrypted[encryptionKey.Size():])
+
+		if includeCertificate {
+			require.Equal(t, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: entry.Certificate().Raw}), files[PEPKCertificateEntry])
+		} else {
+			require.NotContains(t, files, PEPKCertificateEntry)
+		}
+	}
+
+	t.Log("the encryption public key has to be an RSA key")
+	{
+		ecEntry := readTestEntry(t, "testdata/ec.p12", "EC Key")
+		ecPublicKey, err := x509.MarshalPKIXPublicKey(ecEntry.Certificate().PublicKey)
+		require.NoError(t, err)
+
+		_, err = ExportEncryptedKey(entry, ecPublicKey, false)
+		require.EqualError(t, err, "encryption public key is not an RSA key: EC")
+	}
+}
+
+func readTestZip(t *testing.T, data []byte) map[string][]byte {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = content
+	}
+	return files
+}
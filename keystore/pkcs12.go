@@ -0,0 +1,132 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// errNoMAC is returned for PKCS#12 keystores without integrity MAC, which store password can not be verified.
+var errNoMAC = errors.New("PKCS12 keystore has no integrity MAC, the keystore password can not be verified")
+
+// readPKCS12 reads PKCS#12 keystores with a single private key with go-pkcs12.
+// Keystores with more private keys, with keys unknown to crypto/x509 (DSA) or with unknown bags, attributes
+// or algorithms are left to keytool, other keystores are reported as invalid.
+func readPKCS12(data []byte, storePassword string) ([]keystoreEntry, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, storePassword)
+	if err != nil {
+		return nil, pkcs12Error(err)
+	}
+
+	alias, err := pkcs12Alias(data, storePassword)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := append([]*x509.Certificate{cert}, caCerts...)
+	var leaf *x509.Certificate
+	for _, c := range certs {
+		if matchesPublicKey(key, c.PublicKey) {
+			leaf = c
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("entry (%s): none of the certificates belongs to the private key", alias)
+	}
+
+	return []keystoreEntry{{
+		alias:        alias,
+		certificates: certificateChain(leaf, certs),
+		decryptKey: func(keyPassword string) (crypto.PrivateKey, error) {
+			// keytool protects the keys of PKCS#12 keystores with the store password.
+			if keyPassword != "" && keyPassword != storePassword {
+				return nil, ErrWrongKeyPassword
+			}
+			return key, nil
+		},
+	}}, nil
+}
+
+// pkcs12Error maps the errors of go-pkcs12 to the errors of Read.
+func pkcs12Error(err error) error {
+	switch {
+	case errors.Is(err, pkcs12.ErrIncorrectPassword):
+		return ErrWrongStorePassword
+	case err.Error() == "pkcs12: no MAC in data":
+		// go-pkcs12 does not export an error value for MAC-less keystores.
+		return errNoMAC
+	case isUnsupportedPKCS12(err):
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, err)
+	default:
+		return fmt.Errorf("invalid PKCS12 keystore: %s", err)
+	}
+}
+
+// isUnsupportedPKCS12 reports the errors of go-pkcs12 for valid keystores it can not read.
+// Apart from NotImplementedError, go-pkcs12 does not export error values for them.
+func isUnsupportedPKCS12(err error) bool {
+	var notImplemented pkcs12.NotImplementedError
+	if errors.As(err, &notImplemented) {
+		return true
+	}
+	for _, prefix := range []string{
+		"pkcs12: expected exactly one key bag",
+		"pkcs12: error parsing PKCS#8 private key",
+		"pkcs12: found unknown private key type",
+		"pkcs12: don't know how to convert a safe bag of type",
+		"pkcs12: unknown attribute with OID",
+	} {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pkcs12Alias returns the friendly name of the private key, which keytool and jarsigner use as alias.
+// ToPEM is the only API of go-pkcs12 exposing the bag attributes, only the headers of its blocks are used.
+func pkcs12Alias(data []byte, storePassword string) (string, error) {
+	blocks, err := pkcs12.ToPEM(data, storePassword)
+	if err != nil {
+		return "", pkcs12Error(err)
+	}
+	for _, block := range blocks {
+		if block.Type != "PRIVATE KEY" {
+			continue
+		}
+		if alias := block.Headers["friendlyName"]; alias != "" {
+			return alias, nil
+		}
+	}
+	return "", fmt.Errorf("%w: private key has no friendly name", ErrUnsupportedType)
+}
+
+// certificateChain orders the issuers of leaf from certs, stopping at a self-signed certificate.
+func certificateChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+	for current := leaf; len(chain) <= len(certs); {
+		if bytes.Equal(current.RawIssuer, current.RawSubject) {
+			break
+		}
+
+		var issuer *x509.Certificate
+		for _, cert := range certs {
+			if bytes.Equal(cert.RawSubject, current.RawIssuer) && current.CheckSignatureFrom(cert) == nil {
+				issuer = cert
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+		current = issuer
+	}
+	return chain
+}
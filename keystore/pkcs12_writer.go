@@ -0,0 +1,324 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// pkcs12Iterations is the iteration count of the key derivations, the default of keytool.
+const pkcs12Iterations = 10000
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidShroudedKeyBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256           = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,omitempty"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// encodePKCS12 writes a PKCS#12 keystore with the private key entry, in the format keytool writes since JDK 12:
+// the key and the certificates are encrypted with PBES2 (PBKDF2 with HMAC-SHA256, AES-256-CBC),
+// the keystore is protected with an HMAC-SHA256 MAC. Both are derived from the store password.
+// The alias is stored as the friendlyName of the key, which go-pkcs12 can not write.
+func encodePKCS12(entry PrivateKeyEntry, storePassword string) ([]byte, error) {
+	if len(entry.CertificateChain) == 0 {
+		return nil, errors.New("private key entry has no certificate")
+	}
+
+	localKeyID := sha1.Sum(entry.CertificateChain[0].Raw)
+	attributes, err := pkcs12BagAttributes(entry.Alias, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := marshalPKCS8PrivateKey(entry.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	keyAlgorithm, encryptedKey, err := pbes2Encrypt(key, storePassword)
+	if err != nil {
+		return nil, err
+	}
+	shroudedKey, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: keyAlgorithm, EncryptedData: encryptedKey})
+	if err != nil {
+		return nil, err
+	}
+	keyContents, err := asn1.Marshal([]safeBag{{ID: oidShroudedKeyBag, Value: explicitContent(shroudedKey), Attributes: attributes}})
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := asn1.Marshal(keyContents)
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+	for i, cert := range entry.CertificateChain {
+		bag, err := asn1.Marshal(certBag{ID: oidX509Certificate, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		certSafeBag := safeBag{ID: oidCertBag, Value: explicitContent(bag)}
+		if i == 0 {
+			certSafeBag.Attributes = attributes
+		}
+		certBags = append(certBags, certSafeBag)
+	}
+	certContents, err := asn1.Marshal(certBags)
+	if err != nil {
+		return nil, err
+	}
+	certAlgorithm, encryptedCerts, err := pbes2Encrypt(certContents, storePassword)
+	if err != nil {
+		return nil, err
+	}
+	certData, err := asn1.Marshal(encryptedData{EncryptedContentInfo: encryptedContentInfo{
+		ContentType:                oidDataContentType,
+		ContentEncryptionAlgorithm: certAlgorithm,
+		EncryptedContent:           encryptedCerts,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := asn1.Marshal([]contentInfo{
+		{ContentType: oidEncryptedDataContentType, Content: explicitContent(certData)},
+		{ContentType: oidDataContentType, Content: explicitContent(keyData)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mac, err := pkcs12MAC(authSafe, storePassword)
+	if err != nil {
+		return nil, err
+	}
+	authSafeData, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pfxPDU{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicitContent(authSafeData)},
+		MacData:  mac,
+	})
+}
+
+// explicitContent wraps der in the [0] EXPLICIT tag of the content of ContentInfo and SafeBag structures.
+func explicitContent(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// pkcs12BagAttributes returns the friendlyName and localKeyId attributes, which link the key to its certificate.
+func pkcs12BagAttributes(alias string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	friendlyName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagBMPString, Bytes: bmpString(alias, false)})
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return []pkcs12Attribute{
+		{ID: oidFriendlyName, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: friendlyName}},
+		{ID: oidLocalKeyID, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: keyID}},
+	}, nil
+}
+
+// pbes2Encrypt encrypts plaintext with AES-256-CBC, with the key derived from password with PBKDF2-HMAC-SHA256.
+func pbes2Encrypt(plaintext []byte, password string) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt, err := randomBytes(16)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, pkcs12Iterations, 32, sha256.New))
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}}, ciphertext, nil
+}
+
+// pkcs12MAC returns the HMAC-SHA256 MAC of the authenticated safe, keyed with the PKCS#12 key derivation of password.
+func pkcs12MAC(authSafe []byte, password string) (macData, error) {
+	salt, err := randomBytes(sha256.Size)
+	if err != nil {
+		return macData{}, err
+	}
+
+	const macKeyID = 3
+	mac := hmac.New(sha256.New, pkcs12KDF(sha256.New, salt, bmpString(password, true), pkcs12Iterations, macKeyID, sha256.Size))
+	mac.Write(authSafe)
+
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			Digest:    mac.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: pkcs12Iterations,
+	}, nil
+}
+
+// pkcs12KDF derives size bytes of key material of purpose id from password (RFC 7292, appendix B.2).
+func pkcs12KDF(newHash func() hash.Hash, salt, password []byte, iterations int, id byte, size int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+	fill := func(b []byte) []byte {
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(password)...)
+	var key []byte
+	for len(key) < size {
+		h.Reset()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(nil)
+		}
+		key = append(key, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(8v) for every v byte block of I, B is A repeated to v bytes.
+		b := fill(a)
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(i[j+k]) + int(b[k]) + carry
+				i[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return key[:size]
+}
+
+// bmpString encodes s as a big-endian UTF-16 BMPString, passwords are terminated with two zero bytes.
+func bmpString(s string, terminated bool) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	if terminated {
+		b = append(b, 0, 0)
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package keystore
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePKCS12(t *testing.T) {
+	entry := readTestEntry(t, "testdata/rsa-chain.p12", "upload")
+	entry.Alias = "Upload Key"
+
+	data, err := encodePKCS12(entry, "newpass")
+	require.NoError(t, err)
+
+	t.Log("keeps the alias, the key and the certificate chain")
+	{
+		ks, err := Read(data, TypeUnknown, "newpass")
+		require.NoError(t, err)
+		require.Equal(t, TypePKCS12, ks.Type)
+		require.Equal(t, []string{"Upload Key"}, ks.Aliases())
+
+		readEntry, err := ks.PrivateKeyEntry("Upload Key", "")
+		require.NoError(t, err)
+		require.True(t, equalPrivateKeys(entry.PrivateKey, readEntry.PrivateKey))
+		require.Equal(t, entry.CertificateChain, readEntry.CertificateChain)
+	}
+
+	t.Log("is protected with the store password")
+	{
+		_, err := Read(data, TypePKCS12, "storepass")
+		require.True(t, errors.Is(err, ErrWrongStorePassword), err)
+	}
+}
+
+func TestPKCS12KDF(t *testing.T) {
+	// The test vector of the PKCS#12 key derivation of Bouncy Castle and OpenSSL.
+	salt := []byte{0x0a, 0x58, 0xcf, 0x64, 0x53, 0x0d, 0x82, 0x3f}
+	key := pkcs12KDF(sha1.New, salt, bmpString("smeg", true), 1, 1, 24)
+	require.Equal(t, "8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3", hex.EncodeToString(key))
+}
@@ -0,0 +1,262 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Errors returned when opening a keystore or reading a private key entry.
+var (
+	ErrWrongStorePassword = errors.New("keystore password is incorrect")
+	ErrWrongKeyPassword   = errors.New("key password is incorrect")
+	ErrAliasNotFound      = errors.New("alias not found in keystore")
+	// ErrUnsupportedType is returned for keystore types which can only be read by keytool.
+	ErrUnsupportedType = errors.New("keystore type is not supported by the native reader")
+)
+
+// Keystore is a keystore which integrity has been verified with the store password.
+type Keystore struct {
+	Type    Type
+	entries []keystoreEntry
+}
+
+// keystoreEntry is an entry of a Keystore, the private key is still encrypted with the key password.
+type keystoreEntry struct {
+	alias        string
+	decryptKey   func(keyPassword string) (crypto.PrivateKey, error)
+	certificates []*x509.Certificate
+}
+
+// PrivateKeyEntry is a decrypted private key with its certificate chain, starting with the certificate of the key.
+type PrivateKeyEntry struct {
+	Alias            string
+	PrivateKey       crypto.PrivateKey
+	CertificateChain []*x509.Certificate
+}
+
+// ReadFile reads the keystore at pth. If keystoreType is TypeUnknown, the type is detected from the content.
+func ReadFile(pth string, keystoreType Type, storePassword string) (*Keystore, error) {
+	data, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	return Read(data, keystoreType, storePassword)
+}
+
+// Read parses a JKS or PKCS#12 keystore and verifies its integrity with storePassword.
+// ErrUnsupportedType is returned for JCEKS keystores and for PKCS#12 keystores go-pkcs12 can not read, these are left to keytool.
+func Read(data []byte, keystoreType Type, storePassword string) (*Keystore, error) {
+	if keystoreType == TypeUnknown {
+		var err error
+		if keystoreType, err = detectType(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []keystoreEntry
+	var err error
+	switch keystoreType {
+	case TypeJKS:
+		entries, err = readJKS(data, storePassword)
+	case TypePKCS12:
+		entries, err = readPKCS12(data, storePassword)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, keystoreType.StoreType())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{Type: keystoreType, entries: entries}, nil
+}
+
+// Aliases returns the sorted aliases of the private key entries.
+func (ks *Keystore) Aliases() []string {
+	var aliases []string
+	for _, entry := range ks.entries {
+		if entry.decryptKey != nil {
+			aliases = append(aliases, entry.alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// PrivateKeyEntry decrypts the private key entry of alias. Aliases are case-insensitive, as in keytool.
+func (ks *Keystore) PrivateKeyEntry(alias, keyPassword string) (PrivateKeyEntry, error) {
+	for _, entry := range ks.entries {
+		if !strings.EqualFold(entry.alias, alias) {
+			continue
+		}
+		if entry.decryptKey == nil {
+			return PrivateKeyEntry{}, fmt.Errorf("%w: %s is a trusted certificate entry, not a private key entry", ErrAliasNotFound, alias)
+		}
+		if len(entry.certificates) == 0 {
+			return PrivateKeyEntry{}, fmt.Errorf("private key entry (%s) has no certificate", alias)
+		}
+
+		key, err := entry.decryptKey(keyPassword)
+		if err != nil {
+			return PrivateKeyEntry{}, err
+		}
+		return PrivateKeyEntry{
+			Alias:            entry.alias,
+			PrivateKey:       key,
+			CertificateChain: entry.certificates,
+		}, nil
+	}
+	return PrivateKeyEntry{}, fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+}
+
+// Certificate returns the certificate of the private key.
+func (e PrivateKeyEntry) Certificate() *x509.Certificate {
+	return e.CertificateChain[0]
+}
+
+// KeyAlgorithm returns the private key algorithm as named by Java: RSA, EC, DSA or Ed25519.
+func (e PrivateKeyEntry) KeyAlgorithm() string {
+	return publicKeyAlgorithm(e.Certificate().PublicKey)
+}
+
+// KeySize returns the size of the private key in bits.
+func (e PrivateKeyEntry) KeySize() int {
+	return publicKeySize(e.Certificate().PublicKey)
+}
+
+func publicKeyAlgorithm(publicKey interface{}) string {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PublicKey:
+		return "EC"
+	case *dsa.PublicKey:
+		return "DSA"
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+func publicKeySize(publicKey interface{}) int {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case *dsa.PublicKey:
+		return key.P.BitLen()
+	case ed25519.PublicKey:
+		return 255
+	default:
+		return 0
+	}
+}
+
+var oidDSA = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 1}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs8PrivateKeyInfo struct {
+	Version    int
+	Algorithm  algorithmIdentifier
+	PrivateKey []byte
+}
+
+// parsePKCS8PrivateKey extends x509.ParsePKCS8PrivateKey with DSA keys, the default of old keytool versions.
+func parsePKCS8PrivateKey(der []byte) (crypto.PrivateKey, error) {
+	var info pkcs8PrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidDSA) {
+		return x509.ParsePKCS8PrivateKey(der)
+	}
+
+	var params struct {
+		P, Q, G *big.Int
+	}
+	if err := unmarshalParameters(info.Algorithm.Parameters, &params); err != nil {
+		return nil, err
+	}
+	x := new(big.Int)
+	if _, err := asn1.Unmarshal(info.PrivateKey, &x); err != nil {
+		return nil, fmt.Errorf("invalid DSA private key: %s", err)
+	}
+
+	key := &dsa.PrivateKey{
+		PublicKey: dsa.PublicKey{Parameters: dsa.Parameters{P: params.P, Q: params.Q, G: params.G}},
+		X:         x,
+	}
+	key.Y = new(big.Int).Exp(key.G, key.X, key.P)
+	return key, nil
+}
+
+func unmarshalParameters(raw asn1.RawValue, v interface{}) error {
+	rest, err := asn1.Unmarshal(raw.FullBytes, v)
+	if err != nil {
+		return fmt.Errorf("invalid algorithm parameters: %s", err)
+	}
+	if len(rest) > 0 {
+		return errors.New("invalid algorithm parameters: trailing data")
+	}
+	return nil
+}
+
+// marshalPKCS8PrivateKey extends x509.MarshalPKCS8PrivateKey with DSA keys.
+func marshalPKCS8PrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	dsaKey, ok := key.(*dsa.PrivateKey)
+	if !ok {
+		return x509.MarshalPKCS8PrivateKey(key)
+	}
+
+	params, err := asn1.Marshal(struct{ P, Q, G *big.Int }{dsaKey.P, dsaKey.Q, dsaKey.G})
+	if err != nil {
+		return nil, err
+	}
+	x, err := asn1.Marshal(dsaKey.X)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8PrivateKeyInfo{
+		Algorithm:  algorithmIdentifier{Algorithm: oidDSA, Parameters: asn1.RawValue{FullBytes: params}},
+		PrivateKey: x,
+	})
+}
+
+// equalPrivateKeys compares private keys, including DSA keys which do not implement Equal.
+func equalPrivateKeys(a, b crypto.PrivateKey) bool {
+	if dsaKey, ok := a.(*dsa.PrivateKey); ok {
+		other, ok := b.(*dsa.PrivateKey)
+		return ok && dsaKey.X.Cmp(other.X) == 0 && dsaKey.Y.Cmp(other.Y) == 0 &&
+			dsaKey.P.Cmp(other.P) == 0 && dsaKey.Q.Cmp(other.Q) == 0 && dsaKey.G.Cmp(other.G) == 0
+	}
+	key, ok := a.(interface{ Equal(crypto.PrivateKey) bool })
+	return ok && key.Equal(b)
+}
+
+// parseCertificates parses DER certificates, the first one being the certificate of the key.
+func parseCertificates(ders [][]byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %s", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,106 @@
+package keystore
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestReadPKCS12(t *testing.T) {
+	tests := []struct {
+		name         string
+		pth          string
+		alias        string
+		keyAlgorithm string
+		keySize      int
+		chainLen     int
+	}{
+		{name: "PBES2 AES-256, HMAC-SHA256", pth: "testdata/rsa-chain.p12", alias: "upload", keyAlgorithm: "RSA", keySize: 2048, chainLen: 2},
+		{name: "legacy RC2-40 and 3DES, HMAC-SHA1", pth: "testdata/rsa-chain-legacy.p12", alias: "upload", keyAlgorithm: "RSA", keySize: 2048, chainLen: 2},
+		{name: "EC key", pth: "testdata/ec.p12", alias: "EC Key", keyAlgorithm: "EC", keySize: 256, chainLen: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := ReadFile(tt.pth, TypeUnknown, "storepass")
+			require.NoError(t, err)
+			require.Equal(t, TypePKCS12, ks.Type)
+			require.Equal(t, []string{tt.alias}, ks.Aliases())
+
+			entry, err := ks.PrivateKeyEntry(tt.alias, "")
+			require.NoError(t, err)
+			require.Equal(t, tt.keyAlgorithm, entry.KeyAlgorithm())
+			require.Equal(t, tt.keySize, entry.KeySize())
+			require.Equal(t, tt.chainLen, len(entry.CertificateChain))
+			if tt.chainLen > 1 {
+				require.NoError(t, entry.CertificateChain[0].CheckSignatureFrom(entry.CertificateChain[1]))
+			}
+
+			_, err = ks.PrivateKeyEntry(tt.alias, "wrong")
+			require.True(t, errors.Is(err, ErrWrongKeyPassword), err)
+
+			_, err = ks.PrivateKeyEntry("missing", "")
+			require.True(t, errors.Is(err, ErrAliasNotFound), err)
+
+			_, err = ReadFile(tt.pth, TypePKCS12, "wrong")
+			require.True(t, errors.Is(err, ErrWrongStorePassword), err)
+		})
+	}
+}
+
+func TestReadPKCS12Rejected(t *testing.T) {
+	entry := readTestEntry(t, "testdata/ec.p12", "EC Key")
+
+	t.Log("rejects keystores without integrity MAC")
+	{
+		data, err := pkcs12.Passwordless.Encode(entry.PrivateKey, entry.Certificate(), nil, "")
+		require.NoError(t, err)
+
+		_, err = Read(data, TypePKCS12, "storepass")
+		require.Equal(t, errNoMAC, err)
+	}
+
+	t.Log("leaves keystores without alias and with DSA keys to keytool")
+	{
+		data, err := pkcs12.Modern.Encode(entry.PrivateKey, entry.Certificate(), nil, "storepass")
+		require.NoError(t, err)
+
+		_, err = Read(data, TypePKCS12, "storepass")
+		require.True(t, errors.Is(err, ErrUnsupportedType), err)
+
+		_, err = ReadFile("testdata/dsa.p12", TypePKCS12, "storepass")
+		require.True(t, errors.Is(err, ErrUnsupportedType), err)
+	}
+}
+
+func TestReadPKCS12Invalid(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/ec.p12")
+	require.NoError(t, err)
+
+	t.Log("does not leave corrupt keystores to keytool")
+	{
+		_, err := Read(data[:len(data)/2], TypePKCS12, "storepass")
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrUnsupportedType), err)
+		require.Contains(t, err.Error(), "invalid PKCS12 keystore")
+	}
+
+	t.Log("reports the wrong store password")
+	{
+		_, err := Read(data, TypePKCS12, "wrong")
+		require.True(t, errors.Is(err, ErrWrongStorePassword), err)
+	}
+}
+
+func TestReadUnsupportedType(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/ec.p12")
+	require.NoError(t, err)
+
+	_, err = Read(data, TypeBKS, "storepass")
+	require.True(t, errors.Is(err, ErrUnsupportedType))
+
+	_, err = Read(data, TypeJCEKS, "storepass")
+	require.True(t, errors.Is(err, ErrUnsupportedType))
+}
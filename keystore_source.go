@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	keystoreFileName = "keystore"
+	secretFileMode   = 0600
+)
+
+// keystoreResolver makes a keystore referenced by an URL available as a local file.
+type keystoreResolver interface {
+	// resolve returns the local path of the keystore. If the keystore needs to be fetched or decoded,
+	// it is written to fileName inside the workspace and the returned bool is true.
+	resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error)
+}
+
+// keystoreResolvers maps URL schemes to the resolver handling them.
+type keystoreResolvers map[string]keystoreResolver
+
+func newKeystoreResolvers(keystoreDownloader downloader, s3Config s3Config, vault *vaultClient) keystoreResolvers {
+	httpResolver := httpKeystoreResolver{downloader: keystoreDownloader}
+	resolvers := keystoreResolvers{
+		"file":  fileKeystoreResolver{},
+		"http":  httpResolver,
+		"https": httpResolver,
+		"data":  dataURIKeystoreResolver{},
+		"env":   envVarKeystoreResolver{},
+		"s3":    s3KeystoreResolver{downloader: keystoreDownloader, config: s3Config},
+	}
+	resolvers["vault"] = vaultKeystoreResolver{client: vault, resolvers: resolvers}
+	return resolvers
+}
+
+// resolve dispatches keystoreURL to the resolver registered for its scheme.
+func (r keystoreResolvers) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	scheme, _, found := strings.Cut(keystoreURL, ":")
+	if !found {
+		return "", false, errors.New("keystore url has no scheme, use file:// for local keystores")
+	}
+
+	resolver, ok := r[strings.ToLower(scheme)]
+	if !ok {
+		var schemes []string
+		for scheme := range r {
+			schemes = append(schemes, scheme)
+		}
+		sort.Strings(schemes)
+		return "", false, fmt.Errorf("unsupported keystore url scheme (%s), supported schemes: %s", scheme, strings.Join(schemes, ", "))
+	}
+	return resolver.resolve(keystoreURL, fileName, ws)
+}
+
+// fileKeystoreResolver resolves file://PATH/TO/keystore.jks urls.
+type fileKeystoreResolver struct{}
+
+func (fileKeystoreResolver) resolve(keystoreURL, _ string, _ *workspace) (string, bool, error) {
+	pth, ok := trimPrefixFold(keystoreURL, "file://")
+	if !ok {
+		return "", false, errors.New("invalid file url, expected format: file://PATH/TO/keystore.jks")
+	}
+	keystorePath, err := pathutil.AbsPath(pth)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to expand path (%s): %s", pth, err)
+	}
+	return keystorePath, false, nil
+}
+
+// httpKeystoreResolver downloads http:// and https:// urls.
+type httpKeystoreResolver struct {
+	downloader downloader
+}
+
+func (r httpKeystoreResolver) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	log.Infof("Download keystore")
+	keystorePath := ws.path(fileName)
+	ws.trackSecret(keystorePath)
+	if err := r.downloader.download(keystoreURL, keystorePath); err != nil {
+		return "", false, fmt.Errorf("failed to download keystore: %s", err)
+	}
+	return keystorePath, true, nil
+}
+
+// dataURIKeystoreResolver decodes data:[<mediatype>][;base64],<data> urls.
+type dataURIKeystoreResolver struct{}
+
+func (dataURIKeystoreResolver) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	log.Infof("Decode keystore from data URI")
+	content, err := decodeDataURI(keystoreURL)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decode keystore data URI: %s", err)
+	}
+	keystorePath, err := ws.writeSecret(fileName, content)
+	return keystorePath, true, err
+}
+
+// envVarKeystoreResolver decodes env://VAR_NAME urls, where the variable holds the base64 encoded keystore.
+type envVarKeystoreResolver struct{}
+
+func (envVarKeystoreResolver) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	envKey, ok := trimPrefixFold(keystoreURL, "env://")
+	if !ok {
+		return "", false, errors.New("invalid env url, expected format: env://VAR_NAME")
+	}
+	log.Infof("Decode keystore from environment variable: %s", envKey)
+	content, err := decodeBase64EnvVar(envKey)
+	if err != nil {
+		return "", false, err
+	}
+	keystorePath, err := ws.writeSecret(fileName, content)
+	return keystorePath, true, err
+}
+
+// decodeDataURI decodes an RFC 2397 data URI: data:[<mediatype>][;base64],<data>
+func decodeDataURI(uri string) ([]byte, error) {
+	header, payload, found := strings.Cut(uri[len("data:"):], ",")
+	if !found {
+		return nil, errors.New("missing ',' separator")
+	}
+
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		return decodeBase64(payload)
+	}
+
+	content, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// decodeBase64EnvVar decodes the base64 encoded content of the envKey environment variable.
+func decodeBase64EnvVar(envKey string) ([]byte, error) {
+	if envKey == "" {
+		return nil, errors.New("environment variable name is missing from the keystore url (env://VAR_NAME)")
+	}
+
+	value, ok := os.LookupEnv(envKey)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable (%s) is not set", envKey)
+	}
+
+	content, err := decodeBase64(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64 decode environment variable (%s): %s", envKey, err)
+	}
+	return content, nil
+}
+
+// decodeBase64 accepts standard and URL-safe alphabets, with or without padding,
+// and ignores line breaks introduced by tools like `base64` without `-w 0`.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\r', ' ', '\t':
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(s, "=")
+
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// writeSecretFile creates pth readable and writable only by the current user.
+func writeSecretFile(pth string, content []byte) error {
+	f, err := os.OpenFile(pth, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, secretFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		if closeErr := f.Close(); closeErr != nil {
+			log.Warnf("Failed to close file: %s, error: %s", pth, closeErr)
+		}
+		return err
+	}
+	return f.Close()
+}
+
+// fileSHA256 returns the lowercase hex encoded SHA-256 digest of the file at pth.
+func fileSHA256(pth string) (string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close file: %s, error: %s", pth, err)
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// normalizeSHA256 accepts hex digests in lower or upper case,
+// optionally separated by colons as printed by keytool.
+func normalizeSHA256(digest string) (string, error) {
+	normalized := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(digest)))
+	if decoded, err := hex.DecodeString(normalized); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 digest: %s", digest)
+	}
+	return normalized, nil
+}
+
+// trimPrefixFold removes prefix from s, matching it case-insensitively.
+func trimPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
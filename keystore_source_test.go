@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveKeystore(t *testing.T) {
+	content := []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x01, 0xff}
+	encoded := base64.StdEncoding.EncodeToString(content)
+	testResolvers := newKeystoreResolvers(downloader{}, s3Config{}, nil)
+
+	t.Log("file:// url refers to the local file")
+	{
+		pth, isTemporary, err := testResolvers.resolve("file:///path/to/keystore.jks", keystoreFileName, newTestWorkspace(t))
+		require.NoError(t, err)
+		require.False(t, isTemporary)
+		require.Equal(t, "/path/to/keystore.jks", pth)
+	}
+
+	t.Log("base64 data URI")
+	{
+		pth, isTemporary, err := testResolvers.resolve("data:application/octet-stream;base64,"+encoded, keystoreFileName, newTestWorkspace(t))
+		require.NoError(t, err)
+		require.True(t, isTemporary)
+		requireSecretFile(t, pth, content)
+	}
+
+	t.Log("base64 environment variable")
+	{
+		require.NoError(t, os.Setenv("TEST_KEYSTORE_BASE64", encoded[:4]+"\n"+encoded[4:]))
+		defer func() {
+			require.NoError(t, os.Unsetenv("TEST_KEYSTORE_BASE64"))
+		}()
+
+		pth, isTemporary, err := testResolvers.resolve("env://TEST_KEYSTORE_BASE64", keystoreFileName, newTestWorkspace(t))
+		require.NoError(t, err)
+		require.True(t, isTemporary)
+		requireSecretFile(t, pth, content)
+	}
+
+	t.Log("unsupported scheme")
+	{
+		_, _, err := testResolvers.resolve("ftp://example.com/keystore.jks", keystoreFileName, newTestWorkspace(t))
+		require.EqualError(t, err, "unsupported keystore url scheme (ftp), supported schemes: data, env, file, http, https, s3, vault")
+	}
+
+	t.Log("missing environment variable")
+	{
+		_, _, err := testResolvers.resolve("env://TEST_KEYSTORE_NOT_SET", keystoreFileName, newTestWorkspace(t))
+		require.EqualError(t, err, "environment variable (TEST_KEYSTORE_NOT_SET) is not set")
+	}
+}
+
+func TestDecodeDataURI(t *testing.T) {
+	content, err := decodeDataURI("data:;base64,_u3-7Q")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xfe, 0xed, 0xfe, 0xed}, content)
+
+	content, err = decodeDataURI("data:,hello%20world")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(content))
+
+	_, err = decodeDataURI("data:application/octet-stream;base64")
+	require.Error(t, err)
+}
+
+func requireSecretFile(t *testing.T, pth string, content []byte) {
+	info, err := os.Stat(pth)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+
+	actual, err := ioutil.ReadFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, content, actual)
+	require.Equal(t, keystoreFileName, filepath.Base(pth))
+}
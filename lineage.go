@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	lineagePathEnvKey    = "BITRISE_LINEAGE_PATH"
+	lineageSignersEnvKey = "BITRISE_LINEAGE_SIGNERS"
+)
+
+// SignerCapabilities are the capabilities a signer of a lineage grants to the signers following it.
+type SignerCapabilities struct {
+	InstalledData bool `json:"installed_data"`
+	SharedUID     bool `json:"shared_uid"`
+	Permission    bool `json:"permission"`
+	Rollback      bool `json:"rollback"`
+}
+
+// defaultSignerCapabilities are the capabilities granted by apksigner rotate by default.
+var defaultSignerCapabilities = SignerCapabilities{InstalledData: true, SharedUID: true, Permission: true}
+
+// parseSignerCapabilities parses the lineage_capabilities input, e.g. installed_data=true,shared_uid=true,permission=true,rollback=false.
+// Capabilities which are not listed keep their default.
+func parseSignerCapabilities(list string) (SignerCapabilities, error) {
+	capabilities := defaultSignerCapabilities
+	for _, element := range splitElements(parseList(list), ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+		name, value, found := strings.Cut(element, "=")
+		if !found {
+			return SignerCapabilities{}, fmt.Errorf("invalid capability (%s), expected format: name=true|false", element)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return SignerCapabilities{}, fmt.Errorf("invalid value of capability %s: %s", name, value)
+		}
+
+		switch strings.TrimSpace(name) {
+		case "installed_data":
+			capabilities.InstalledData = enabled
+		case "shared_uid":
+			capabilities.SharedUID = enabled
+		case "permission":
+			capabilities.Permission = enabled
+		case "rollback":
+			capabilities.Rollback = enabled
+		default:
+			return SignerCapabilities{}, fmt.Errorf("unknown capability: %s, supported capabilities: installed_data, shared_uid, permission, rollback", name)
+		}
+	}
+	return capabilities, nil
+}
+
+func createCapabilitiesCmdSlice(capabilities SignerCapabilities) []string {
+	return []string{
+		"--set-installed-data", strconv.FormatBool(capabilities.InstalledData),
+		"--set-shared-uid", strconv.FormatBool(capabilities.SharedUID),
+		"--set-permission", strconv.FormatBool(capabilities.Permission),
+		"--set-rollback", strconv.FormatBool(capabilities.Rollback),
+	}
+}
+
+// RotateConfiguration creates a signing certificate lineage from the old signer to the new signer with apksigner rotate,
+// or extends an existing lineage, whose last signer is the old signer.
+type RotateConfiguration struct {
+	apkSigner             string
+	inLineagePth          string
+	outLineagePth         string
+	oldSigner             KeystoreSignatureConfiguration
+	newSigner             KeystoreSignatureConfiguration
+	oldSignerCapabilities SignerCapabilities
+}
+
+func (configuration RotateConfiguration) createRotateCmd() ([]string, error) {
+	oldSignerSlice, err := createKeystoreCmdSlice(&configuration.oldSigner)
+	if err != nil {
+		return nil, err
+	}
+	newSignerSlice, err := createKeystoreCmdSlice(&configuration.newSigner)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdSlice := []string{configuration.apkSigner, "rotate"}
+	if configuration.inLineagePth != "" {
+		cmdSlice = append(cmdSlice, "--in", configuration.inLineagePth)
+	}
+	cmdSlice = append(cmdSlice, "--out", configuration.outLineagePth, "--old-signer")
+	cmdSlice = append(cmdSlice, oldSignerSlice...)
+	cmdSlice = append(cmdSlice, createCapabilitiesCmdSlice(configuration.oldSignerCapabilities)...)
+	cmdSlice = append(cmdSlice, "--new-signer")
+	cmdSlice = append(cmdSlice, newSignerSlice...)
+	return cmdSlice, nil
+}
+
+// Rotate writes the lineage.
+func (configuration RotateConfiguration) Rotate() error {
+	cmdSlice, err := configuration.createRotateCmd()
+	if err != nil {
+		return fmt.Errorf("failed to create rotate command: %v", err)
+	}
+
+	log.Printf("=> %s", command.PrintableCommandArgs(false, secureSignCmd(cmdSlice)))
+	out, err := executeForOutput(cmdSlice)
+	if err != nil {
+		return properError(err, out)
+	}
+	return nil
+}
+
+// LineageSigner is a signer of a signing certificate lineage, the oldest signer being the first.
+type LineageSigner struct {
+	Subject      string             `json:"subject"`
+	SHA256       string             `json:"sha256"`
+	Capabilities SignerCapabilities `json:"capabilities"`
+}
+
+// InspectLineage returns the signers of the lineage with apksigner lineage --print-certs.
+func InspectLineage(apkSigner, lineagePth string) ([]LineageSigner, error) {
+	cmdSlice := []string{apkSigner, "lineage", "--in", lineagePth, "--print-certs", "-v"}
+
+	log.Printf("=> %s", command.PrintableCommandArgs(false, cmdSlice))
+	out, err := executeForOutput(cmdSlice)
+	if err != nil {
+		return nil, properError(err, out)
+	}
+
+	signers := parseLineageSigners(out)
+	if len(signers) == 0 {
+		return nil, errors.New("no signer found in lineage")
+	}
+	return signers, nil
+}
+
+var (
+	lineageSignerPattern     = regexp.MustCompile(`^Signer #(\d+) in lineage certificate (DN|SHA-256 digest): (.+)$`)
+	lineageCapabilityPattern = regexp.MustCompile(`^Has (installed data|shared UID|permission|rollback) capability\s*:\s*(true|false)$`)
+)
+
+// parseLineageSigners parses the output of apksigner lineage --print-certs -v,
+// where the capabilities of a signer are printed after its certificate.
+func parseLineageSigners(out string) []LineageSigner {
+	var signers []LineageSigner
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if match := lineageSignerPattern.FindStringSubmatch(line); match != nil {
+			i, err := strconv.Atoi(match[1])
+			if err != nil || i < 1 || i > len(signers)+1 {
+				continue
+			}
+			if i == len(signers)+1 {
+				signers = append(signers, LineageSigner{})
+			}
+			if match[2] == "DN" {
+				signers[i-1].Subject = match[3]
+			} else {
+				signers[i-1].SHA256 = strings.ToLower(match[3])
+			}
+		} else if match := lineageCapabilityPattern.FindStringSubmatch(line); match != nil && len(signers) > 0 {
+			capabilities := &signers[len(signers)-1].Capabilities
+			enabled := match[2] == "true"
+			switch match[1] {
+			case "installed data":
+				capabilities.InstalledData = enabled
+			case "shared UID":
+				capabilities.SharedUID = enabled
+			case "permission":
+				capabilities.Permission = enabled
+			case "rollback":
+				capabilities.Rollback = enabled
+			}
+		}
+	}
+	return signers
+}
+
+func runLineage(cfg configs, mode string, ws *workspace, resolvers keystoreResolvers, vault *vaultClient) {
+	apkSigner, err := buildAPKSignerPath()
+	if err != nil {
+		failf("Run: %s", err)
+	}
+
+	var inLineagePath string
+	if cfg.LineageURL != "" {
+		if inLineagePath, _, err = resolvers.resolve(cfg.LineageURL, "lineage", ws); err != nil {
+			failf("Run: failed to get lineage: %s", err)
+		}
+	}
+
+	lineagePath := inLineagePath
+	if mode == createLineageMode {
+		lineagePath = cfg.LineageOutputPath
+		err := createLineage(cfg, apkSigner, inLineagePath, ws, resolvers, vault)
+		failOnKeystoreError("Run: failed to create lineage", err)
+		if err := tools.ExportEnvironmentWithEnvman(lineagePathEnvKey, lineagePath); err != nil {
+			log.Warnf("Failed to export path (%s), error: %s", lineagePath, err)
+		} else {
+			log.Donef("The path is now available in the Environment Variable: %s (value: %s), sign with it as lineage_url: file://$%s", lineagePathEnvKey, lineagePath, lineagePathEnvKey)
+		}
+	}
+
+	fmt.Println()
+	log.Infof("Inspect lineage")
+	signers, err := InspectLineage(apkSigner, lineagePath)
+	if err != nil {
+		failf("Run: failed to inspect lineage: %s", err)
+	}
+	signersJSON, err := json.MarshalIndent(signers, "", "  ")
+	if err != nil {
+		failf("Run: failed to encode lineage signers: %s", err)
+	}
+	log.Printf("%s", signersJSON)
+	if err := tools.ExportEnvironmentWithEnvman(lineageSignersEnvKey, string(signersJSON)); err != nil {
+		log.Warnf("Failed to export lineage signers, error: %s", err)
+	} else {
+		log.Donef("The signers of the lineage are now available in the Environment Variable: %s", lineageSignersEnvKey)
+	}
+}
+
+// createLineage writes the lineage from the signing key of keystore_url to the key of rotation_keystore_url to lineage_output_path.
+// Existing files are never overwritten.
+func createLineage(cfg configs, apkSigner, inLineagePath string, ws *workspace, resolvers keystoreResolvers, vault *vaultClient) error {
+	if err := ensureNotExists(cfg.LineageOutputPath); err != nil {
+		return err
+	}
+	capabilities, err := parseSignerCapabilities(cfg.LineageCapabilities)
+	if err != nil {
+		return err
+	}
+
+	oldSigner, err := openKeystore(ws, resolvers, vault, signingCredentials{
+		name:             "keystore_url",
+		keystoreURL:      string(cfg.KeystoreURL),
+		keystorePassword: string(cfg.KeystorePassword),
+		alias:            string(cfg.KeystoreAlias),
+		keyPassword:      string(cfg.PrivateKeyPassword),
+		keystoreSHA256:   cfg.KeystoreSHA256,
+	}, keystoreFileName, cfg.KeystoreType)
+	if err != nil {
+		return err
+	}
+	newSigner, err := openKeystore(ws, resolvers, vault, rotationCredentials(cfg), rotationKeystoreFileName, cfg.RotationKeystoreType)
+	if err != nil {
+		return fmt.Errorf("rotation keystore: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.LineageOutputPath), 0755); err != nil {
+		return err
+	}
+	log.Infof("Rotate from %s to %s", oldSigner.helper.CertificateInfo().Subject, newSigner.helper.CertificateInfo().Subject)
+	return RotateConfiguration{
+		apkSigner:             apkSigner,
+		inLineagePth:          inLineagePath,
+		outLineagePth:         cfg.LineageOutputPath,
+		oldSigner:             keystoreSignatureConfiguration(oldSigner),
+		newSigner:             keystoreSignatureConfiguration(newSigner),
+		oldSignerCapabilities: capabilities,
+	}.Rotate()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignerCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    SignerCapabilities
+		wantErr bool
+	}{
+		{name: "defaults", list: "", want: defaultSignerCapabilities},
+		{name: "all capabilities", list: "installed_data=false, shared_uid=false, permission=false, rollback=true", want: SignerCapabilities{Rollback: true}},
+		{name: "unlisted capabilities keep their default", list: "rollback=true\npermission=false", want: SignerCapabilities{InstalledData: true, SharedUID: true, Rollback: true}},
+		{name: "unknown capability", list: "auth=true", wantErr: true},
+		{name: "invalid value", list: "rollback=yes", wantErr: true},
+		{name: "missing value", list: "rollback", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignerCapabilities(tt.list)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateRotateCmd(t *testing.T) {
+	configuration := RotateConfiguration{
+		apkSigner:     "apksigner",
+		outLineagePth: "lineage",
+		oldSigner: KeystoreSignatureConfiguration{
+			keystorePth:      "old.jks",
+			keystorePassword: "oldpass",
+			keystoreType:     keystore.TypeJKS,
+			alias:            "old",
+			aliasPassword:    "oldkeypass",
+		},
+		newSigner: KeystoreSignatureConfiguration{
+			keystorePth:      "new.p12",
+			keystorePassword: "newpass",
+			keystoreType:     keystore.TypePKCS12,
+			alias:            "new",
+		},
+		oldSignerCapabilities: SignerCapabilities{InstalledData: true, SharedUID: true, Permission: true, Rollback: true},
+	}
+
+	t.Log("creates a lineage")
+	{
+		cmdSlice, err := configuration.createRotateCmd()
+		require.NoError(t, err)
+		require.Equal(t, "apksigner rotate --out lineage --old-signer --ks old.jks --ks-pass *** --ks-key-alias old --ks-type JKS --key-pass *** --set-installed-data true --set-shared-uid true --set-permission true --set-rollback true --new-signer --ks new.p12 --ks-pass *** --ks-key-alias new --ks-type PKCS12", strings.Join(secureSignCmd(cmdSlice), " "))
+	}
+
+	t.Log("extends an existing lineage")
+	{
+		configuration.inLineagePth = "old-lineage"
+		cmdSlice, err := configuration.createRotateCmd()
+		require.NoError(t, err)
+		require.Equal(t, []string{"apksigner", "rotate", "--in", "old-lineage", "--out", "lineage", "--old-signer"}, cmdSlice[:7])
+	}
+}
+
+func TestParseLineageSigners(t *testing.T) {
+	out := `Signer #1 in lineage certificate DN: CN=Old Key
+Signer #1 in lineage certificate SHA-256 digest: 4A0B7C2D
+Signer #1 in lineage certificate SHA-1 digest: 0123
+Signer #1 in lineage key algorithm: RSA
+Has installed data capability: true
+Has shared UID capability    : true
+Has permission capability    : false
+Has rollback capability      : true
+Has auth capability          : true
+
+Signer #2 in lineage certificate DN: CN=New Key, O=Example
+Signer #2 in lineage certificate SHA-256 digest: 9f8e7d6c
+Has installed data capability: true
+Has shared UID capability    : true
+Has permission capability    : true
+Has rollback capability      : false
+`
+	require.Equal(t, []LineageSigner{
+		{Subject: "CN=Old Key", SHA256: "4a0b7c2d", Capabilities: SignerCapabilities{InstalledData: true, SharedUID: true, Rollback: true}},
+		{Subject: "CN=New Key, O=Example", SHA256: "9f8e7d6c", Capabilities: SignerCapabilities{InstalledData: true, SharedUID: true, Permission: true}},
+	}, parseLineageSigners(out))
+}
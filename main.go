@@ -3,12 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bitrise-io/go-android/sdk"
 	"github.com/bitrise-io/go-steputils/stepconf"
@@ -22,23 +22,103 @@ import (
 
 var signingFileExts = []string{".mf", ".rsa", ".dsa", ".ec", ".sf"}
 
+// Exit codes
+const (
+	generalErrorExitCode       = 1
+	keystoreIntegrityErrorCode = 2
+)
+
 // -----------------------
 // --- Models
 // -----------------------
 
 type configs struct {
-	BuildArtifactPath  string `env:"android_app,required"`
-	KeystoreURL        string `env:"keystore_url,required"`
-	KeystorePassword   string `env:"keystore_password,required"`
-	KeystoreAlias      string `env:"keystore_alias,required"`
-	PrivateKeyPassword string `env:"private_key_password"`
-	OutputName         string `env:"output_name"`
+	Mode string `env:"mode,opt[sign,generate_keystore,convert_keystore,export_encrypted_key,create_lineage,inspect_lineage]"`
+
+	BuildArtifactPath  string          `env:"android_app"`
+	KeystoreURL        stepconf.Secret `env:"keystore_url"`
+	KeystorePassword   stepconf.Secret `env:"keystore_password"`
+	KeystoreAlias      stepconf.Secret `env:"keystore_alias"`
+	PrivateKeyPassword stepconf.Secret `env:"private_key_password"`
+	PrivateKeyURL      stepconf.Secret `env:"private_key_url"`
+	CertificateURL     string          `env:"certificate_url"`
+	OutputName         string          `env:"output_name"`
+
+	RotationKeystoreURL        stepconf.Secret `env:"rotation_keystore_url"`
+	RotationKeystorePassword   stepconf.Secret `env:"rotation_keystore_password"`
+	RotationKeystoreAlias      stepconf.Secret `env:"rotation_keystore_alias"`
+	RotationPrivateKeyPassword stepconf.Secret `env:"rotation_private_key_password"`
+	RotationKeystoreType       string          `env:"rotation_keystore_type,opt[automatic,jks,pkcs12,jceks,bks]"`
+	LineageURL                 string          `env:"lineage_url"`
+	RotationMinSDKVersion      int             `env:"rotation_min_sdk_version"`
+	RotationTargetsDevRelease  bool            `env:"rotation_targets_dev_release,opt[true,false]"`
+	LineageCapabilities        string          `env:"lineage_capabilities"`
+	LineageOutputPath          string          `env:"lineage_output_path"`
+
+	StampKeystoreURL        stepconf.Secret `env:"stamp_keystore_url"`
+	StampKeystorePassword   stepconf.Secret `env:"stamp_keystore_password"`
+	StampKeystoreAlias      stepconf.Secret `env:"stamp_keystore_alias"`
+	StampPrivateKeyPassword stepconf.Secret `env:"stamp_private_key_password"`
+	StampKeystoreType       string          `env:"stamp_keystore_type,opt[automatic,jks,pkcs12,jceks,bks]"`
+
+	MinSDKVersion     int    `env:"min_sdk_version"`
+	MaxSDKVersion     int    `env:"max_sdk_version"`
+	VerifySDKVersions string `env:"verify_sdk_versions"`
 
 	VerboseLog          bool   `env:"verbose_log,opt[true,false]"`
 	PageAlign           string `env:"page_align,opt[automatic,true,false]"`
-	SignerScheme        string `env:"signer_scheme,opt[automatic,v2,v3,v4]"`
+	SignerScheme        string `env:"signer_scheme,required"`
 	DebuggablePermitted string `env:"debuggable_permitted,opt[true,false]"`
 	SignerTool          string `env:"signer_tool,opt[automatic,apksigner,jarsigner]"`
+	KeystoreType        string `env:"keystore_type,opt[automatic,jks,pkcs12,jceks,bks]"`
+	KeystoreSHA256      string `env:"keystore_sha256"`
+	FallbackCredentials string `env:"fallback_credentials"`
+	AdditionalSigners   string `env:"additional_signers"`
+	KeepIntermediates   bool   `env:"keep_intermediates,opt[true,false]"`
+
+	CertificateValidityCheck   string `env:"certificate_validity_check,opt[fail,warn,off]"`
+	CertificateMinValidityDays int    `env:"certificate_min_validity_days"`
+	CertificateValidUntil      string `env:"certificate_valid_until"`
+
+	AllowedKeyAlgorithms                  string `env:"allowed_key_algorithms"`
+	MinKeySizes                           string `env:"min_key_sizes"`
+	AllowedCertificateSignatureAlgorithms string `env:"allowed_certificate_signature_algorithms"`
+
+	GenerateOutputDir         string `env:"generate_output_dir"`
+	GenerateDistinguishedName string `env:"generate_distinguished_name"`
+	GenerateKeyAlgorithm      string `env:"generate_key_algorithm,opt[rsa_2048,rsa_4096,ec_p256]"`
+	GenerateValidityDays      int    `env:"generate_validity_days"`
+
+	ConvertedKeystorePath     string          `env:"converted_keystore_path"`
+	ConvertedKeystorePassword stepconf.Secret `env:"converted_keystore_password"`
+
+	EncryptionPublicKeyURL   string `env:"encryption_public_key_url"`
+	ExportIncludeCertificate bool   `env:"export_include_certificate,opt[true,false]"`
+	ExportOutputPath         string `env:"export_output_path"`
+
+	DownloadConnectTimeout int `env:"keystore_download_connect_timeout"`
+	DownloadReadTimeout    int `env:"keystore_download_read_timeout"`
+	DownloadRetries        int `env:"keystore_download_retries"`
+
+	DownloadHeaders        stepconf.Secret `env:"keystore_download_headers"`
+	DownloadBasicAuth      stepconf.Secret `env:"keystore_download_basic_auth"`
+	DownloadCABundlePath   string          `env:"keystore_download_ca_bundle_path"`
+	DownloadClientCertPath string          `env:"keystore_download_client_cert_path"`
+	DownloadClientKeyPath  string          `env:"keystore_download_client_key_path"`
+	DownloadProxyURL       stepconf.Secret `env:"keystore_download_proxy_url"`
+
+	S3Endpoint        string          `env:"s3_endpoint"`
+	S3Region          string          `env:"s3_region"`
+	S3AccessKeyID     stepconf.Secret `env:"s3_access_key_id"`
+	S3SecretAccessKey stepconf.Secret `env:"s3_secret_access_key"`
+	S3SessionToken    stepconf.Secret `env:"s3_session_token"`
+
+	VaultAddress      string          `env:"vault_address"`
+	VaultNamespace    string          `env:"vault_namespace"`
+	VaultToken        stepconf.Secret `env:"vault_token"`
+	VaultRoleID       string          `env:"vault_role_id"`
+	VaultSecretID     stepconf.Secret `env:"vault_secret_id"`
+	VaultAppRoleMount string          `env:"vault_approle_mount"`
 
 	// Deprecated
 	APKPath string `env:"apk_path"`
@@ -82,6 +162,11 @@ func splitElements(list []string, sep string) (s []string) {
 }
 
 func parseAppList(list string) (apps []string) {
+	return parseList(list)
+}
+
+// parseList splits a newline or | separated input list.
+func parseList(list string) (elements []string) {
 	list = strings.TrimSpace(list)
 	if len(list) == 0 {
 		return nil
@@ -92,10 +177,10 @@ func parseAppList(list string) (apps []string) {
 		s = splitElements(s, sep)
 	}
 
-	for _, app := range s {
-		app = strings.TrimSpace(app)
-		if len(app) > 0 {
-			apps = append(apps, app)
+	for _, e := range s {
+		e = strings.TrimSpace(e)
+		if len(e) > 0 {
+			elements = append(elements, e)
 		}
 	}
 	return
@@ -105,31 +190,6 @@ func parseAppList(list string) (apps []string) {
 // --- Functions
 // -----------------------
 
-func download(url, pth string) error {
-	out, err := os.Create(pth)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Warnf("Failed to close file: %s, error: %s", out, err)
-		}
-	}()
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("Failed to close response body, error: %s", err)
-		}
-	}()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
-
 func listFilesInBuildArtifact(aapt, pth string) ([]string, error) {
 	cmdSlice := []string{aapt, "list", pth}
 	out, err := keystore.ExecuteForOutput(cmdSlice)
@@ -218,9 +278,112 @@ func prettyBuildArtifactBasename(buildArtifactPth string) string {
 	return buildArtifactBasename
 }
 
+// checkKeystoreIntegrity logs the SHA-256 digest of the keystore and compares it to expectedSHA256 if set.
+func checkKeystoreIntegrity(keystorePath, expectedSHA256 string) error {
+	digest, err := fileSHA256(keystorePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate keystore digest: %s", err)
+	}
+	log.Printf("Keystore SHA-256 digest: %s", digest)
+
+	if expectedSHA256 == "" {
+		return nil
+	}
+
+	expected, err := normalizeSHA256(expectedSHA256)
+	if err != nil {
+		return err
+	}
+	if digest != expected {
+		return fmt.Errorf("keystore SHA-256 digest (%s) does not match the expected digest (%s)", digest, expected)
+	}
+
+	log.Donef("Keystore SHA-256 digest matches the expected digest")
+	return nil
+}
+
+func newKeystoreDownloader(cfg configs) (downloader, error) {
+	headers, err := parseHeaders(string(cfg.DownloadHeaders))
+	if err != nil {
+		return downloader{}, err
+	}
+
+	username, password, err := parseBasicAuth(string(cfg.DownloadBasicAuth))
+	if err != nil {
+		return downloader{}, err
+	}
+
+	return newDownloader(downloadConfig{
+		connectTimeout:    time.Duration(cfg.DownloadConnectTimeout) * time.Second,
+		readTimeout:       time.Duration(cfg.DownloadReadTimeout) * time.Second,
+		retries:           cfg.DownloadRetries,
+		headers:           headers,
+		basicAuthUsername: username,
+		basicAuthPassword: password,
+		caBundlePath:      cfg.DownloadCABundlePath,
+		clientCertPath:    cfg.DownloadClientCertPath,
+		clientKeyPath:     cfg.DownloadClientKeyPath,
+		proxyURL:          string(cfg.DownloadProxyURL),
+	})
+}
+
+// resolveKeystoreType detects the format of the keystore and applies the keystore_type input override.
+func resolveKeystoreType(keystorePath, typeInput string) (keystore.Type, error) {
+	detectedType, err := keystore.DetectType(keystorePath)
+	if err != nil && !errors.Is(err, keystore.ErrUnknownType) {
+		return keystore.TypeUnknown, err
+	}
+
+	if typeInput == "" || typeInput == "automatic" {
+		if detectedType == keystore.TypeUnknown {
+			log.Warnf("Could not detect the keystore format, set keystore_type if the keystore can not be opened")
+		} else {
+			log.Printf("Detected keystore type: %s", detectedType.StoreType())
+		}
+		return detectedType, nil
+	}
+
+	keystoreType, err := keystore.ParseType(typeInput)
+	if err != nil {
+		return keystore.TypeUnknown, err
+	}
+	if detectedType != keystore.TypeUnknown && detectedType != keystoreType {
+		log.Warnf("keystore_type is set to %s, but the keystore looks like %s", keystoreType.StoreType(), detectedType.StoreType())
+	}
+	return keystoreType, nil
+}
+
 func failf(format string, v ...interface{}) {
-	log.Errorf(format, v)
-	os.Exit(1)
+	failWithCodef(generalErrorExitCode, format, v...)
+}
+
+func failWithCodef(exitCode int, format string, v ...interface{}) {
+	log.Errorf(format, v...)
+	if stepWorkspace != nil {
+		stepWorkspace.cleanup()
+	}
+	os.Exit(exitCode)
+}
+
+// failOnKeystoreError fails with the keystore integrity exit code on keystore integrity errors
+// and with prefix on other errors.
+func failOnKeystoreError(prefix string, err error) {
+	var integrityErr keystoreIntegrityError
+	if errors.As(err, &integrityErr) {
+		failWithCodef(keystoreIntegrityErrorCode, "Run: keystore integrity check failed: %s", err)
+	} else if err != nil {
+		failf("%s: %s", prefix, err)
+	}
+}
+
+// ensureNotExists returns an error if a file exists at pth, outputs never overwrite existing files.
+func ensureNotExists(pth string) error {
+	if _, err := os.Stat(pth); err == nil {
+		return fmt.Errorf("file already exists at: %s, refusing to overwrite it", pth)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 func handleDeprecatedInputs(cfg *configs) {
@@ -230,8 +393,106 @@ func handleDeprecatedInputs(cfg *configs) {
 	}
 }
 
-func validate(cfg configs) error {
+// validateKeystoreInputs validates the inputs used to download and open the keystore, or the private key and certificate.
+func validateKeystoreInputs(cfg configs, mode string) error {
+	switch {
+	case mode == inspectLineageMode:
+		if cfg.LineageURL == "" {
+			return fmt.Errorf("lineage_url is required in %s mode", inspectLineageMode)
+		}
+	case mode == signMode && cfg.PrivateKeyURL != "":
+		if cfg.CertificateURL == "" {
+			return fmt.Errorf("certificate_url is required if private_key_url is set")
+		}
+	default:
+		if cfg.KeystoreURL == "" {
+			return fmt.Errorf("keystore_url is required")
+		}
+		if cfg.KeystorePassword == "" {
+			return fmt.Errorf("keystore_password is required")
+		}
+	}
+
+	if mode == createLineageMode {
+		if cfg.RotationKeystoreURL == "" {
+			return fmt.Errorf("rotation_keystore_url is required in %s mode", createLineageMode)
+		}
+		if cfg.RotationKeystorePassword == "" {
+			return fmt.Errorf("rotation_keystore_password is required in %s mode", createLineageMode)
+		}
+		if _, err := parseSignerCapabilities(cfg.LineageCapabilities); err != nil {
+			return fmt.Errorf("lineage_capabilities: %s", err)
+		}
+	}
+
+	if mode == signMode && cfg.RotationKeystoreURL != "" {
+		if cfg.RotationKeystorePassword == "" {
+			return fmt.Errorf("rotation_keystore_password is required if rotation_keystore_url is set")
+		}
+		if cfg.LineageURL == "" {
+			return fmt.Errorf("lineage_url is required if rotation_keystore_url is set")
+		}
+		if cfg.RotationMinSDKVersion < 0 {
+			return fmt.Errorf("rotation_min_sdk_version must not be negative")
+		}
+		if cfg.AdditionalSigners != "" {
+			return fmt.Errorf("additional_signers can not be used together with rotation_keystore_url")
+		}
+	}
+
+	if mode == signMode {
+		if _, err := parseAdditionalSigners(cfg.AdditionalSigners); err != nil {
+			return fmt.Errorf("additional_signers: %s", err)
+		}
+		if cfg.RotationKeystoreURL != "" || cfg.LineageURL != "" {
+			schemes, err := parseSignerSchemes(cfg.SignerScheme)
+			if err != nil {
+				return fmt.Errorf("signer_scheme: %s", err)
+			}
+			if err := schemes.checkKeyRotation(); err != nil {
+				return fmt.Errorf("signer_scheme: %s", err)
+			}
+		}
+		if cfg.StampKeystoreURL != "" && cfg.StampKeystorePassword == "" {
+			return fmt.Errorf("stamp_keystore_password is required if stamp_keystore_url is set")
+		}
+		verifySDKVersions, err := parseSDKVersions(cfg.VerifySDKVersions)
+		if err != nil {
+			return fmt.Errorf("verify_sdk_versions: %s", err)
+		}
+		if err := checkSDKVersions(cfg.MinSDKVersion, cfg.MaxSDKVersion, verifySDKVersions); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DownloadConnectTimeout < 0 || cfg.DownloadReadTimeout < 0 {
+		return fmt.Errorf("keystore download timeouts must not be negative")
+	}
+	if cfg.DownloadRetries < 0 {
+		return fmt.Errorf("keystore download retries must not be negative")
+	}
+
+	if cfg.KeystoreSHA256 != "" {
+		if _, err := normalizeSHA256(cfg.KeystoreSHA256); err != nil {
+			return fmt.Errorf("keystore_sha256: %s", err)
+		}
+	}
+
+	if _, err := parseCertificateValidityPolicy(cfg.CertificateValidityCheck, cfg.CertificateMinValidityDays, cfg.CertificateValidUntil); err != nil {
+		return err
+	}
+	if _, err := parseKeyPolicy(cfg.AllowedKeyAlgorithms, cfg.MinKeySizes, cfg.AllowedCertificateSignatureAlgorithms); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate validates the inputs and the certificate of the signing key, before anything is signed.
+func validate(cfg configs, certificate keystore.CertificateInfo) error {
 	buildArtifactPaths := parseAppList(cfg.BuildArtifactPath)
+	if len(buildArtifactPaths) == 0 {
+		return fmt.Errorf("android_app is required")
+	}
 	for _, buildArtifactPath := range buildArtifactPaths {
 		if exist, err := pathutil.IsPathExists(buildArtifactPath); err != nil {
 			return fmt.Errorf("failed to check if BuildArtifactPath exist at: %s, error: %s", buildArtifactPath, err)
@@ -245,6 +506,34 @@ func validate(cfg configs) error {
 			failf("signer tool apksigner does not support signing AABs, please use automatic or jarsigner instead")
 		}
 	}
+
+	return checkCertificate(cfg, "signing", certificate)
+}
+
+// checkCertificate checks the certificate of a signing key against the key and validity policies.
+func checkCertificate(cfg configs, name string, certificate keystore.CertificateInfo) error {
+	signingKeyPolicy, err := parseKeyPolicy(cfg.AllowedKeyAlgorithms, cfg.MinKeySizes, cfg.AllowedCertificateSignatureAlgorithms)
+	if err != nil {
+		return err
+	}
+	if err := signingKeyPolicy.check(certificate); err != nil {
+		return fmt.Errorf("%s key policy check failed: %s", name, err)
+	}
+
+	validityPolicy, err := parseCertificateValidityPolicy(cfg.CertificateValidityCheck, cfg.CertificateMinValidityDays, cfg.CertificateValidUntil)
+	if err != nil {
+		return err
+	}
+	violations := validityPolicy.validityViolations(certificate, time.Now())
+	if len(violations) == 0 {
+		return nil
+	}
+	if validityPolicy.mode == validityCheckFail {
+		return fmt.Errorf("%s certificate validity check failed: %s", name, strings.Join(violations, ", "))
+	}
+	for _, violation := range violations {
+		log.Warnf("%s certificate validity: %s", strings.ToUpper(name[:1])+name[1:], violation)
+	}
 	return nil
 }
 
@@ -256,6 +545,10 @@ func main() {
 	if err := stepconf.Parse(&cfg); err != nil {
 		failf("Process config: failed to parse input: %s", err)
 	}
+	// signer_scheme takes a list of schemes next to the presets, so it is validated here instead of with opt[...].
+	if _, err := parseSignerSchemes(cfg.SignerScheme); err != nil {
+		failf("Process config: failed to parse input: signer_scheme: %s", err)
+	}
 	pageAlignConfig := parsePageAlign(cfg.PageAlign)
 
 	stepconf.Print(cfg)
@@ -263,36 +556,130 @@ func main() {
 	handleDeprecatedInputs(&cfg)
 	fmt.Println()
 
-	if err := validate(cfg); err != nil {
+	mode, err := stepMode(cfg.Mode, os.Args)
+	if err != nil {
+		failf("Process config: %s", err)
+	}
+	if mode == generateKeystoreMode {
+		runGenerateKeystore(cfg)
+		return
+	}
+
+	if err := validateKeystoreInputs(cfg, mode); err != nil {
 		failf("Process config: failed to validate input: %s", err)
 	}
 
 	// Download keystore
-	tmpDir, err := pathutil.NormalizedOSTempDirPath("bitrise-sign-build-artifact")
+	ws, err := newWorkspace("bitrise-sign-build-artifact", cfg.KeepIntermediates)
 	if err != nil {
 		failf("Run: failed to create tmp dir: %s", err)
 	}
+	stepWorkspace = ws
+	ws.cleanupOnSignal()
+	defer ws.cleanup()
 
-	keystorePath := ""
-	if strings.HasPrefix(cfg.KeystoreURL, "file://") {
-		pth := strings.TrimPrefix(cfg.KeystoreURL, "file://")
-		var err error
-		keystorePath, err = pathutil.AbsPath(pth)
+	keystoreDownloader, err := newKeystoreDownloader(cfg)
+	if err != nil {
+		failf("Run: failed to configure keystore download: %s", err)
+	}
+	vault := newVaultClient(vaultConfig{
+		address:      cfg.VaultAddress,
+		namespace:    cfg.VaultNamespace,
+		token:        string(cfg.VaultToken),
+		roleID:       cfg.VaultRoleID,
+		secretID:     string(cfg.VaultSecretID),
+		appRoleMount: cfg.VaultAppRoleMount,
+	}, keystoreDownloader.client)
+
+	resolvers := newKeystoreResolvers(keystoreDownloader, s3Config{
+		endpoint:        cfg.S3Endpoint,
+		region:          cfg.S3Region,
+		accessKeyID:     string(cfg.S3AccessKeyID),
+		secretAccessKey: string(cfg.S3SecretAccessKey),
+		sessionToken:    string(cfg.S3SessionToken),
+	}, vault)
+	if mode == convertKeystoreMode {
+		runConvertKeystore(cfg, ws, resolvers, vault)
+		return
+	}
+	if mode == exportEncryptedKeyMode {
+		runExportEncryptedKey(cfg, ws, resolvers, vault)
+		return
+	}
+	if mode == createLineageMode || mode == inspectLineageMode {
+		runLineage(cfg, mode, ws, resolvers, vault)
+		return
+	}
+
+	var signingKeystore openedKeystore
+	var keyCertificate *keyCertificateFiles
+	if cfg.PrivateKeyURL != "" {
+		log.Infof("Open private key and certificate")
+		signingKeystore, keyCertificate, err = openKeyCertificate(ws, resolvers, vault, string(cfg.PrivateKeyURL), cfg.CertificateURL, string(cfg.PrivateKeyPassword))
+		failOnKeystoreError("Run", err)
+	} else {
+		fallbackCredentials, err := parseFallbackCredentials(cfg.FallbackCredentials)
 		if err != nil {
-			failf("Run: failed to expand path (%s): %s", pth, err)
+			failf("Process config: %s", err)
 		}
-	} else {
-		log.Infof("Download keystore")
-		keystorePath = path.Join(tmpDir, "keystore.jks")
-		if err := download(cfg.KeystoreURL, keystorePath); err != nil {
-			failf("Run: failed to download keystore: %s", err)
+		credentialSets := append([]signingCredentials{{
+			name:             "keystore_url",
+			keystoreURL:      string(cfg.KeystoreURL),
+			keystorePassword: string(cfg.KeystorePassword),
+			alias:            string(cfg.KeystoreAlias),
+			keyPassword:      string(cfg.PrivateKeyPassword),
+			keystoreSHA256:   cfg.KeystoreSHA256,
+		}}, fallbackCredentials...)
+
+		credentialSetIndex, opened, err := selectCredentials(credentialSets, func(i int, set signingCredentials) (openedKeystore, error) {
+			return openKeystore(ws, resolvers, vault, set, credentialSetFileName(i), set.typeInput(cfg.KeystoreType))
+		})
+		failOnKeystoreError("Run", err)
+		exportCredentialSet(credentialSetIndex, opened.credentials)
+		signingKeystore = opened
+	}
+	certificateInfo := signingKeystore.helper.CertificateInfo()
+	exportCertificateInfo(certificateInfo)
+
+	if err := validate(cfg, certificateInfo); err != nil {
+		failf("Process config: failed to validate input: %s", err)
+	}
+	credentials := signingKeystore.credentials
+
+	var rotation *keyRotation
+	if cfg.RotationKeystoreURL != "" {
+		log.Infof("Open keystore of the rotated signing key")
+		rotation, err = openKeyRotation(ws, resolvers, vault, cfg)
+		failOnKeystoreError("Run", err)
+		rotatedCertificateInfo := rotation.signer.helper.CertificateInfo()
+		log.Printf("Rotated signing certificate: %s, SHA-256: %s", rotatedCertificateInfo.Subject, rotatedCertificateInfo.SHA256.Hex)
+		if err := checkCertificate(cfg, "rotated signing", rotatedCertificateInfo); err != nil {
+			failf("Process config: failed to validate input: %s", err)
 		}
 	}
-	log.Printf("using keystore at: %s", keystorePath)
 
-	keystore, err := keystore.NewHelper(keystorePath, cfg.KeystorePassword, cfg.KeystoreAlias)
-	if err != nil {
-		failf("Run: failed to create keystore helper: %s", err)
+	var additionalSigners []openedKeystore
+	if cfg.AdditionalSigners != "" {
+		log.Infof("Open keystores of the additional signers")
+		additionalSigners, err = openAdditionalSigners(ws, resolvers, vault, cfg)
+		failOnKeystoreError("Run", err)
+		for _, signer := range additionalSigners {
+			signerCertificateInfo := signer.helper.CertificateInfo()
+			log.Printf("Additional signing certificate of %s: %s, SHA-256: %s", signer.credentials.name, signerCertificateInfo.Subject, signerCertificateInfo.SHA256.Hex)
+			if err := checkCertificate(cfg, signer.credentials.name, signerCertificateInfo); err != nil {
+				failf("Process config: failed to validate input: %s", err)
+			}
+		}
+	}
+
+	var stamp *openedKeystore
+	if cfg.StampKeystoreURL != "" {
+		log.Infof("Open keystore of the SourceStamp signer")
+		opened, err := openSourceStamp(ws, resolvers, vault, cfg)
+		failOnKeystoreError("Run", err)
+		stampCertificateInfo := opened.helper.CertificateInfo()
+		log.Printf("SourceStamp certificate: %s, SHA-256: %s", stampCertificateInfo.Subject, stampCertificateInfo.SHA256.Hex)
+		stamp = &opened
 	}
 	// ---
 
@@ -317,10 +704,34 @@ func main() {
 	}
 	log.Printf("zipalign: %s", zipalign)
 
-	apkSigner, err := NewKeystoreSignatureConfiguration(keystorePath, cfg.KeystorePassword, cfg.KeystoreAlias, cfg.PrivateKeyPassword, cfg.DebuggablePermitted, cfg.SignerScheme)
+	signerSchemes, err := parseSignerSchemes(cfg.SignerScheme)
+	if err != nil {
+		failf("Process config: signer_scheme: %s", err)
+	}
+
+	var apkSigner SignatureConfiguration
+	if keyCertificate != nil {
+		apkSigner, err = NewKeyCertificateSignatureConfiguration(keyCertificate.keyPath, keyCertificate.certificatePath, cfg.DebuggablePermitted, signerSchemes)
+	} else {
+		apkSigner, err = NewKeystoreSignatureConfiguration(signingKeystore.path, credentials.keystorePassword, signingKeystore.keystoreType, credentials.alias, credentials.keyPassword, cfg.DebuggablePermitted, signerSchemes)
+	}
 	if err != nil {
 		failf("Run: failed to create signature configuration: %s", err)
 	}
+	if rotation != nil {
+		apkSigner = apkSigner.WithKeyRotation(rotation.signatureConfiguration(cfg))
+	}
+	for _, signer := range additionalSigners {
+		apkSigner = apkSigner.WithSigner(NewKeystoreSignerConfiguration(keystoreSignatureConfiguration(signer)))
+	}
+	if stamp != nil {
+		apkSigner = apkSigner.WithSourceStamp(sourceStampConfiguration(*stamp))
+	}
+	verifySDKVersions, err := parseSDKVersions(cfg.VerifySDKVersions)
+	if err != nil {
+		failf("Process config: verify_sdk_versions: %s", err)
+	}
+	apkSigner = apkSigner.WithSDKVersions(cfg.MinSDKVersion, cfg.MaxSDKVersion, verifySDKVersions)
 	// ---
 
 	// Sign build artifacts
@@ -346,7 +757,7 @@ func main() {
 		buildArtifactBasename := prettyBuildArtifactBasename(buildArtifactPath)
 
 		// unsign build artifact
-		unsignedBuildArtifactPth := filepath.Join(tmpDir, "unsigned"+artifactExt)
+		unsignedBuildArtifactPth := ws.path("unsigned" + artifactExt)
 		if err := command.CopyFile(buildArtifactPath, unsignedBuildArtifactPth); err != nil {
 			failf("Run: failed to copy build artifact: %s", err)
 		}
@@ -361,6 +772,19 @@ func main() {
 			}
 		}
 
+		if signerTool == string(jarsignerSignerTool) && rotation != nil {
+			log.Warnf("Key rotation is not supported by jarsigner, %s is signed with the signing key of %s only", buildArtifactPath, credentials.name)
+		}
+		if signerTool == string(jarsignerSignerTool) && len(additionalSigners) > 0 {
+			log.Warnf("Multiple signers are not supported by jarsigner, %s is signed with the signing key of %s only", buildArtifactPath, credentials.name)
+		}
+		if signerTool == string(jarsignerSignerTool) && stamp != nil {
+			log.Warnf("SourceStamp is not supported by jarsigner, %s is not stamped", buildArtifactPath)
+		}
+		if signerTool == string(jarsignerSignerTool) && !signAAB && (cfg.MinSDKVersion > 0 || cfg.MaxSDKVersion > 0 || len(verifySDKVersions) > 0) {
+			log.Warnf("API level overrides are not supported by jarsigner, min_sdk_version, max_sdk_version and verify_sdk_versions are ignored for %s", buildArtifactPath)
+		}
+
 		if signerTool == string(jarsignerSignerTool) {
 			isSigned, err := isBuildArtifactSigned(aapt, unsignedBuildArtifactPth)
 			if err != nil {
@@ -383,9 +807,11 @@ func main() {
 
 		var fullPath string
 		if signerTool == string(apksignerSignerTool) {
-			fullPath = signAPK(zipalign, unsignedBuildArtifactPth, buildArtifactDir, buildArtifactBasename, artifactExt, cfg.OutputName, apkSigner, pageAlignConfig)
+			minSDKVersion := signerSchemesMinSDKVersion(unsignedBuildArtifactPth, cfg.MinSDKVersion)
+			checkSignerSchemes(signerSchemes, minSDKVersion)
+			fullPath = signAPK(zipalign, unsignedBuildArtifactPth, buildArtifactDir, buildArtifactBasename, artifactExt, cfg.OutputName, apkSigner.WithAPKMinSDKVersion(minSDKVersion), pageAlignConfig)
 		} else {
-			fullPath = signJarSigner(zipalign, tmpDir, unsignedBuildArtifactPth, buildArtifactDir, buildArtifactBasename, artifactExt, cfg.PrivateKeyPassword, cfg.OutputName, keystore, pageAlignConfig)
+			fullPath = signJarSigner(zipalign, ws.dir, unsignedBuildArtifactPth, buildArtifactDir, buildArtifactBasename, artifactExt, credentials.keyPassword, cfg.OutputName, signingKeystore.helper, pageAlignConfig)
 		}
 
 		if signAAB {
@@ -491,6 +917,48 @@ func exportAPK(signedAPKPaths []string, joinedAPKOutputPaths string) {
 	}
 }
 
+// exportCredentialSet exports the 1-based index of the credential set used for signing, 1 being the keystore_url input.
+func exportCredentialSet(i int, set signingCredentials) {
+	credentialSet := strconv.Itoa(i + 1)
+	if err := tools.ExportEnvironmentWithEnvman(credentialSetEnvKey, credentialSet); err != nil {
+		log.Warnf("Failed to export credential set (%s), error: %s", credentialSet, err)
+	} else {
+		log.Donef("Signing with credential set: %s, the set is now available in the Environment Variable: %s (value: %s)", set.name, credentialSetEnvKey, credentialSet)
+	}
+}
+
+// exportCertificateInfo prints the signing certificate and exports its fingerprints.
+func exportCertificateInfo(info keystore.CertificateInfo) {
+	fmt.Println()
+	log.Infof("Signing certificate")
+	log.Printf("Subject: %s", info.Subject)
+	log.Printf("Issuer: %s", info.Issuer)
+	log.Printf("Serial number: %s", info.SerialNumber)
+	log.Printf("Valid from: %s until: %s", info.NotBefore.UTC(), info.NotAfter.UTC())
+	log.Printf("Public key: %s %d bits", info.PublicKeyAlgorithm, info.PublicKeySize)
+	log.Printf("Signature algorithm: %s", info.SignatureAlgorithm)
+
+	fingerprints := []struct {
+		envKey string
+		value  string
+	}{
+		{"BITRISE_SIGNING_CERT_MD5", info.MD5.Hex},
+		{"BITRISE_SIGNING_CERT_SHA1", info.SHA1.Hex},
+		{"BITRISE_SIGNING_CERT_SHA256", info.SHA256.Hex},
+		{"BITRISE_SIGNING_CERT_MD5_BASE64", info.MD5.Base64},
+		{"BITRISE_SIGNING_CERT_SHA1_BASE64", info.SHA1.Base64},
+		{"BITRISE_SIGNING_CERT_SHA256_BASE64", info.SHA256.Base64},
+	}
+	for _, fingerprint := range fingerprints {
+		if err := tools.ExportEnvironmentWithEnvman(fingerprint.envKey, fingerprint.value); err != nil {
+			log.Warnf("Failed to export certificate fingerprint (%s), error: %s", fingerprint.value, err)
+		} else {
+			log.Donef("The certificate fingerprint is now available in the Environment Variable: %s (value: %s)", fingerprint.envKey, fingerprint.value)
+		}
+	}
+	fmt.Println()
+}
+
 func exportAAB(signedAABPaths []string, joinedAABOutputPaths string) {
 	if err := tools.ExportEnvironmentWithEnvman("BITRISE_SIGNED_AAB_PATH", signedAABPaths[len(signedAABPaths)-1]); err != nil {
 		log.Warnf("Failed to export AAB (%s), error: %s", signedAABPaths[len(signedAABPaths)-1], err)
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -95,3 +98,39 @@ func TestFilterSigningFiles(t *testing.T) {
 		require.Equal(t, "META-INF/MANIFEST.SF", metaFiles[0])
 	}
 }
+
+func TestCheckKeystoreIntegrity(t *testing.T) {
+	keystorePath := filepath.Join(t.TempDir(), "keystore.jks")
+	require.NoError(t, ioutil.WriteFile(keystorePath, []byte("keystore"), 0600))
+
+	// echo -n keystore | shasum -a 256
+	digest := "284aaf4da604624b89af5327fadfd2c05bdb818ae8222755e2649dd7a223d244"
+	otherDigest := "3d4e57b1c6b8e2b6e8bf4a1f9c9a5e6e0c8f4e3f7b9bba3b4dd5e1b9c3b9e7a0"
+
+	require.NoError(t, checkKeystoreIntegrity(keystorePath, ""))
+	require.NoError(t, checkKeystoreIntegrity(keystorePath, digest))
+	require.NoError(t, checkKeystoreIntegrity(keystorePath, strings.ToUpper(digest)))
+
+	err := checkKeystoreIntegrity(keystorePath, otherDigest)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match")
+}
+
+func TestNormalizeSHA256(t *testing.T) {
+	digest, err := normalizeSHA256("AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89")
+	require.NoError(t, err)
+	require.Equal(t, "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789", digest)
+
+	_, err = normalizeSHA256("abcdef")
+	require.Error(t, err)
+}
+
+func TestEnsureNotExists(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "keystore.p12")
+	require.NoError(t, ensureNotExists(pth))
+
+	require.NoError(t, ioutil.WriteFile(pth, []byte("keystore"), 0600))
+	err := ensureNotExists(pth)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "refusing to overwrite")
+}
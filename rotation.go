@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+)
+
+const rotationKeystoreFileName = "rotation-keystore"
+
+// keyRotation is the new signer of an APK Signature Scheme v3.1 key rotation with the lineage linking it to the old signer.
+type keyRotation struct {
+	signer      openedKeystore
+	lineagePath string
+}
+
+// openKeyRotation opens the keystore of the new signer and makes the lineage available at a local path.
+func openKeyRotation(ws *workspace, resolvers keystoreResolvers, vault *vaultClient, cfg configs) (*keyRotation, error) {
+	signer, err := openKeystore(ws, resolvers, vault, rotationCredentials(cfg), rotationKeystoreFileName, cfg.RotationKeystoreType)
+	if err != nil {
+		return nil, fmt.Errorf("rotation keystore: %w", err)
+	}
+
+	lineagePath, _, err := resolvers.resolve(cfg.LineageURL, "lineage", ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lineage: %s", err)
+	}
+	log.Printf("using lineage at: %s", lineagePath)
+
+	return &keyRotation{signer: signer, lineagePath: lineagePath}, nil
+}
+
+// rotationCredentials returns the credential set of the new signing key.
+func rotationCredentials(cfg configs) signingCredentials {
+	return signingCredentials{
+		name:             "rotation_keystore_url",
+		keystoreURL:      string(cfg.RotationKeystoreURL),
+		keystorePassword: string(cfg.RotationKeystorePassword),
+		alias:            string(cfg.RotationKeystoreAlias),
+		keyPassword:      string(cfg.RotationPrivateKeyPassword),
+	}
+}
+
+// signatureConfiguration returns the apksigner configuration of the new signer and of the rotation.
+func (r keyRotation) signatureConfiguration(cfg configs) (KeystoreSignatureConfiguration, RotationConfiguration) {
+	return keystoreSignatureConfiguration(r.signer), NewRotationConfiguration(r.lineagePath, cfg.RotationMinSDKVersion, cfg.RotationTargetsDevRelease, certificateDigest(r.signer.helper.CertificateInfo()))
+}
+
+// keystoreSignatureConfiguration returns the apksigner configuration of an opened keystore.
+func keystoreSignatureConfiguration(opened openedKeystore) KeystoreSignatureConfiguration {
+	return KeystoreSignatureConfiguration{
+		keystorePth:      opened.path,
+		keystorePassword: opened.credentials.keystorePassword,
+		keystoreType:     opened.keystoreType,
+		alias:            opened.credentials.alias,
+		aliasPassword:    opened.credentials.keyPassword,
+	}
+}
+
+// certificateDigest returns the SHA-256 digest of the certificate as printed by apksigner: lowercase hex without separators.
+func certificateDigest(info keystore.CertificateInfo) string {
+	return strings.ToLower(strings.ReplaceAll(info.SHA256.Hex, ":", ""))
+}
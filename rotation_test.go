@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateDigest(t *testing.T) {
+	entry, err := keystore.Generate(keystore.GenerateOptions{
+		Alias:             "new",
+		DistinguishedName: "CN=New Key",
+		KeySpec:           keystore.KeySpecECP256,
+		ValidityDays:      1,
+	})
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(entry.Certificate().Raw)
+	require.Equal(t, hex.EncodeToString(digest[:]), certificateDigest(keystore.NewCertificateInfo(entry.Certificate())))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// emptyPayloadHash is the hex encoded SHA-256 digest of an empty request body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4DateFormat  = "20060102T150405Z"
+)
+
+// s3Config configures access to S3 or an S3 compatible object storage.
+type s3Config struct {
+	// endpoint overrides the AWS endpoint (e.g. http://localhost:9000 for MinIO), objects are addressed path-style.
+	endpoint        string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3KeystoreResolver downloads s3://bucket/key urls, authenticated with AWS Signature Version 4.
+type s3KeystoreResolver struct {
+	downloader downloader
+	config     s3Config
+}
+
+func (r s3KeystoreResolver) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	objectURL, err := r.config.objectURL(keystoreURL)
+	if err != nil {
+		return "", false, err
+	}
+
+	log.Infof("Download keystore from S3")
+	keystorePath := ws.path(fileName)
+	ws.trackSecret(keystorePath)
+
+	var sign requestSigner
+	if r.config.accessKeyID != "" {
+		sign = func(req *http.Request) error {
+			return r.config.sign(req, time.Now())
+		}
+	} else {
+		log.Warnf("No S3 credentials provided, downloading the keystore anonymously")
+	}
+
+	if err := r.downloader.downloadSigned(objectURL, keystorePath, sign); err != nil {
+		return "", false, fmt.Errorf("failed to download keystore: %s", err)
+	}
+	return keystorePath, true, nil
+}
+
+// objectURL converts s3://bucket/key to the HTTP url of the object.
+func (c s3Config) objectURL(s3URL string) (string, error) {
+	u, err := url.Parse(s3URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 url: %s", redactURLError(err))
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", errors.New("invalid s3 url, expected format: s3://bucket/key")
+	}
+
+	if c.endpoint != "" {
+		endpoint, err := url.Parse(c.endpoint)
+		if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			return "", fmt.Errorf("invalid s3 endpoint: %s", c.endpoint)
+		}
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + bucket + "/" + key
+		// Send the path exactly as it is encoded in the canonical request.
+		endpoint.RawPath = uriEncode(endpoint.Path, false)
+		return endpoint.String(), nil
+	}
+
+	pth := "/" + key
+	return (&url.URL{
+		Scheme:  "https",
+		Host:    fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, c.regionOrDefault()),
+		Path:    pth,
+		RawPath: uriEncode(pth, false),
+	}).String(), nil
+}
+
+func (c s3Config) regionOrDefault() string {
+	if c.region == "" {
+		return defaultS3Region
+	}
+	return c.region
+}
+
+// sign adds the AWS Signature Version 4 Authorization header to a request without body.
+// All headers set on the request at this point are signed.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (c s3Config) sign(req *http.Request, now time.Time) error {
+	if c.secretAccessKey == "" {
+		return errors.New("S3 secret access key is not set")
+	}
+
+	amzDate := now.UTC().Format(sigV4DateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if strings.EqualFold(name, "Authorization") {
+			continue
+		}
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQueryString(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, c.regionOrDefault(), "s3", "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, c.regionOrDefault())
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, c.accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQueryString(query url.Values) string {
+	var params []string
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte except the RFC 3986 unreserved characters (and '/' if encodeSlash is false).
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSDKVersions parses a comma or newline separated list of API levels.
+func parseSDKVersions(list string) ([]int, error) {
+	var sdkVersions []int
+	for _, element := range splitElements(parseList(list), ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+		sdkVersion, err := strconv.Atoi(element)
+		if err != nil || sdkVersion < 1 {
+			return nil, fmt.Errorf("invalid API level: %s", element)
+		}
+		sdkVersions = append(sdkVersions, sdkVersion)
+	}
+	return sdkVersions, nil
+}
+
+// checkSDKVersions checks that the API level range is valid, and that the API levels to verify at are inside of it.
+// A minSDKVersion or maxSDKVersion of 0 is not set.
+func checkSDKVersions(minSDKVersion, maxSDKVersion int, verifySDKVersions []int) error {
+	if minSDKVersion < 0 {
+		return fmt.Errorf("min_sdk_version must not be negative")
+	}
+	if maxSDKVersion < 0 {
+		return fmt.Errorf("max_sdk_version must not be negative")
+	}
+	if maxSDKVersion > 0 && minSDKVersion > maxSDKVersion {
+		return fmt.Errorf("min_sdk_version (%d) is greater than max_sdk_version (%d)", minSDKVersion, maxSDKVersion)
+	}
+	for _, sdkVersion := range verifySDKVersions {
+		if sdkVersion < minSDKVersion || (maxSDKVersion > 0 && sdkVersion > maxSDKVersion) {
+			return fmt.Errorf("verify_sdk_versions: API level %d is outside of the range of min_sdk_version and max_sdk_version", sdkVersion)
+		}
+	}
+	return nil
+}
+
+func createSDKVersionsCmdSlice(minSDKVersion, maxSDKVersion int) []string {
+	var cmdSlice []string
+	if minSDKVersion > 0 {
+		cmdSlice = append(cmdSlice, "--min-sdk-version", strconv.Itoa(minSDKVersion))
+	}
+	if maxSDKVersion > 0 {
+		cmdSlice = append(cmdSlice, "--max-sdk-version", strconv.Itoa(maxSDKVersion))
+	}
+	return cmdSlice
+}
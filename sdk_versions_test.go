@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSDKVersions(t *testing.T) {
+	t.Log("comma and newline separated API levels")
+	{
+		sdkVersions, err := parseSDKVersions("21, 24\n28|33")
+		require.NoError(t, err)
+		require.Equal(t, []int{21, 24, 28, 33}, sdkVersions)
+	}
+
+	t.Log("empty list")
+	{
+		sdkVersions, err := parseSDKVersions("")
+		require.NoError(t, err)
+		require.Empty(t, sdkVersions)
+	}
+
+	t.Log("invalid API levels")
+	{
+		for _, list := range []string{"21,S", "0", "-1"} {
+			_, err := parseSDKVersions(list)
+			require.Error(t, err, list)
+		}
+	}
+}
+
+func TestCheckSDKVersions(t *testing.T) {
+	tests := []struct {
+		name              string
+		minSDKVersion     int
+		maxSDKVersion     int
+		verifySDKVersions []int
+		wantErr           bool
+	}{
+		{name: "not set"},
+		{name: "range", minSDKVersion: 21, maxSDKVersion: 33, verifySDKVersions: []int{21, 28, 33}},
+		{name: "min only", minSDKVersion: 24, verifySDKVersions: []int{34}},
+		{name: "negative min", minSDKVersion: -1, wantErr: true},
+		{name: "negative max", maxSDKVersion: -1, wantErr: true},
+		{name: "min greater than max", minSDKVersion: 28, maxSDKVersion: 24, wantErr: true},
+		{name: "API level below min", minSDKVersion: 24, verifySDKVersions: []int{21}, wantErr: true},
+		{name: "API level above max", maxSDKVersion: 30, verifySDKVersions: []int{33}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSDKVersions(tt.minSDKVersion, tt.maxSDKVersion, tt.verifySDKVersions)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCreateSDKVersionsCmdSlice(t *testing.T) {
+	require.Empty(t, createSDKVersionsCmdSlice(0, 0))
+	require.Equal(t, "--min-sdk-version 21", strings.Join(createSDKVersionsCmdSlice(21, 0), " "))
+	require.Equal(t, "--max-sdk-version 33", strings.Join(createSDKVersionsCmdSlice(0, 33), " "))
+	require.Equal(t, "--min-sdk-version 21 --max-sdk-version 33", strings.Join(createSDKVersionsCmdSlice(21, 33), " "))
+}
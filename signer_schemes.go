@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// signerSchemeVersions are the APK Signature Scheme versions supported by apksigner.
+var signerSchemeVersions = []int{1, 2, 3, 4}
+
+// SignerSchemes are the APK Signature Scheme versions explicitly enabled (true) or disabled (false).
+// Versions missing from it are resolved as apksigner would enable or disable them, based on the minSdkVersion of the APK.
+type SignerSchemes map[int]bool
+
+// signerSchemePresets are the values of signer_scheme before it accepted a list of schemes.
+var signerSchemePresets = map[string]SignerSchemes{
+	"automatic": {},
+	"v2":        {2: true},
+	"v3":        {3: true},
+	"v4":        {4: true},
+}
+
+// parseSignerSchemes parses the signer_scheme input: a preset or comma separated vN=true|false pairs.
+func parseSignerSchemes(value string) (SignerSchemes, error) {
+	if preset, ok := signerSchemePresets[strings.TrimSpace(value)]; ok {
+		return preset, nil
+	}
+
+	schemes := SignerSchemes{}
+	for _, element := range splitElements(parseList(value), ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+		name, value, found := strings.Cut(element, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid signer scheme (%s), expected a preset (automatic, v2, v3, v4) or vN=true|false pairs", element)
+		}
+		name = strings.TrimSpace(name)
+		version, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+		if err != nil || !strings.HasPrefix(name, "v") || version < 1 || version > len(signerSchemeVersions) {
+			return nil, fmt.Errorf("unknown signer scheme: %s, supported schemes: v1, v2, v3, v4", name)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of signer scheme %s: %s", name, value)
+		}
+		if _, ok := schemes[version]; ok {
+			return nil, fmt.Errorf("signer scheme %s is set more than once", name)
+		}
+		schemes[version] = enabled
+	}
+
+	if len(schemes) == 0 {
+		return nil, fmt.Errorf("invalid signer scheme: %s", value)
+	}
+	if schemes.disabled(1) && schemes.disabled(2) && schemes.disabled(3) {
+		return nil, fmt.Errorf("at least one of the v1, v2 and v3 signer schemes has to be enabled")
+	}
+	return schemes, nil
+}
+
+// disabled returns whether the scheme of version is explicitly disabled.
+func (schemes SignerSchemes) disabled(version int) bool {
+	enabled, ok := schemes[version]
+	return ok && !enabled
+}
+
+// resolve returns every scheme version enabled or disabled: the explicitly set ones as they are set,
+// the others as apksigner enables them by default for an APK of minSDKVersion.
+// v1 is enabled below API level 24, v2 and v3 are always enabled, v4 is enabled if v2 or v3 is.
+func (schemes SignerSchemes) resolve(minSDKVersion int) SignerSchemes {
+	resolved := SignerSchemes{
+		1: minSDKVersion < 24,
+		2: true,
+		3: true,
+	}
+	for version, enabled := range schemes {
+		resolved[version] = enabled
+	}
+	if _, ok := schemes[4]; !ok {
+		resolved[4] = resolved[2] || resolved[3]
+	}
+	return resolved
+}
+
+// checkKeyRotation returns an error if the schemes disable v3 signing, which stores the lineage of a key rotation.
+func (schemes SignerSchemes) checkKeyRotation() error {
+	if schemes.disabled(3) {
+		return fmt.Errorf("v3 signing can not be disabled if rotation_keystore_url or lineage_url is set, the signing certificate lineage is stored in the v3 signature")
+	}
+	return nil
+}
+
+// minSDKVersionWarnings returns the issues of the schemes on the devices supported by an APK of minSDKVersion.
+// apksigner enables v1 signing by default below API level 24 only, so v1 counts as enabled only if it is explicitly enabled from there.
+func (schemes SignerSchemes) minSDKVersionWarnings(minSDKVersion int) []string {
+	var warnings []string
+	if schemes.disabled(1) && minSDKVersion < 24 {
+		warnings = append(warnings, fmt.Sprintf("v1 signing is disabled, but the minSdkVersion of the APK is %d: Android versions below 7.0 (API level 24) verify v1 signatures only", minSDKVersion))
+	}
+	if schemes.disabled(2) && !schemes[1] && minSDKVersion >= 24 && minSDKVersion < 28 {
+		warnings = append(warnings, fmt.Sprintf("v2 signing is disabled, but the minSdkVersion of the APK is %d: Android 7.0 to 8.1 (API level 24 to 27) verify v1 and v2 signatures only", minSDKVersion))
+	}
+	return warnings
+}
+
+// signerSchemesMinSDKVersion returns the minSdkVersion the signer schemes of the APK are resolved and checked for:
+// minSDKVersion if it overrides the manifest, the minSdkVersion of the APK otherwise.
+// If the manifest can not be read, the schemes are resolved for all API levels.
+func signerSchemesMinSDKVersion(apkPath string, minSDKVersion int) int {
+	if minSDKVersion > 0 {
+		return minSDKVersion
+	}
+	minSDKVersion, err := parseAPKMinSDKVersion(apkPath)
+	if err != nil {
+		log.Warnf("Failed to read the minSdkVersion of the APK, the signer schemes are resolved for all API levels: %s", err)
+		return 1
+	}
+	return minSDKVersion
+}
+
+// checkSignerSchemes warns if the schemes leave devices supported by an APK of minSDKVersion without a signature they verify.
+func checkSignerSchemes(schemes SignerSchemes, minSDKVersion int) {
+	for _, warning := range schemes.minSDKVersionWarnings(minSDKVersion) {
+		log.Warnf("%s", warning)
+	}
+}
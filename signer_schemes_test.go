@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignerSchemes(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    SignerSchemes
+		wantErr bool
+	}{
+		{name: "automatic preset", value: "automatic", want: SignerSchemes{}},
+		{name: "v2 preset", value: "v2", want: SignerSchemes{2: true}},
+		{name: "v4 preset", value: "v4", want: SignerSchemes{4: true}},
+		{name: "every scheme", value: "v4=true, v3=true,v2=true,v1=false", want: SignerSchemes{1: false, 2: true, 3: true, 4: true}},
+		{name: "newline separated", value: "v2=true\nv3=true", want: SignerSchemes{2: true, 3: true}},
+		{name: "unknown scheme", value: "v5=true", wantErr: true},
+		{name: "scheme without v", value: "2=true", wantErr: true},
+		{name: "invalid value", value: "v1=no", wantErr: true},
+		{name: "missing value", value: "v1", wantErr: true},
+		{name: "duplicated scheme", value: "v1=true,v1=false", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "nothing to verify", value: "v1=false,v2=false,v3=false,v4=true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSignerSchemes(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateSignerSchemesCmdSlice(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		minSDKVersion int
+		wantCmd       string
+	}{
+		{
+			name:          "automatic preset below API level 24",
+			value:         "automatic",
+			minSDKVersion: 21,
+			wantCmd:       "--v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true",
+		},
+		{
+			name:          "automatic preset from API level 24",
+			value:         "automatic",
+			minSDKVersion: 24,
+			wantCmd:       "--v1-signing-enabled false --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true",
+		},
+		{
+			name:          "v2 preset",
+			value:         "v2",
+			minSDKVersion: 21,
+			wantCmd:       "--v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true",
+		},
+		{
+			name:          "v2 preset from API level 24",
+			value:         "v2",
+			minSDKVersion: 26,
+			wantCmd:       "--v1-signing-enabled false --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true",
+		},
+		{
+			name:          "explicitly enabled v1 from API level 24",
+			value:         "v1=true",
+			minSDKVersion: 28,
+			wantCmd:       "--v1-signing-enabled true --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled true",
+		},
+		{
+			name:          "every scheme",
+			value:         "v4=false, v3=true,v2=true,v1=false",
+			minSDKVersion: 21,
+			wantCmd:       "--v1-signing-enabled false --v2-signing-enabled true --v3-signing-enabled true --v4-signing-enabled false",
+		},
+		{
+			name:          "v4 disabled without v2 and v3",
+			value:         "v1=true,v2=false,v3=false",
+			minSDKVersion: 21,
+			wantCmd:       "--v1-signing-enabled true --v2-signing-enabled false --v3-signing-enabled false --v4-signing-enabled false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schemes, err := parseSignerSchemes(tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCmd, strings.Join(createSignerSchemesCmdSlice(schemes, tt.minSDKVersion), " "))
+		})
+	}
+}
+
+func TestSignerSchemesMinSDKVersionWarnings(t *testing.T) {
+	tests := []struct {
+		name          string
+		schemes       SignerSchemes
+		minSDKVersion int
+		wantWarnings  int
+	}{
+		{name: "automatic", schemes: SignerSchemes{}, minSDKVersion: 21, wantWarnings: 0},
+		{name: "v1 disabled below API level 24", schemes: SignerSchemes{1: false, 2: true}, minSDKVersion: 21, wantWarnings: 1},
+		{name: "v1 disabled from API level 24", schemes: SignerSchemes{1: false, 2: true}, minSDKVersion: 24, wantWarnings: 0},
+		{name: "v2 disabled below API level 28", schemes: SignerSchemes{2: false, 3: true}, minSDKVersion: 26, wantWarnings: 1},
+		{name: "v2 disabled below API level 28 with v1", schemes: SignerSchemes{1: true, 2: false, 3: true}, minSDKVersion: 26, wantWarnings: 0},
+		{name: "v2 disabled from API level 28", schemes: SignerSchemes{2: false, 3: true}, minSDKVersion: 28, wantWarnings: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Len(t, tt.schemes.minSDKVersionWarnings(tt.minSDKVersion), tt.wantWarnings)
+		})
+	}
+}
+
+func TestSignerSchemesCheckKeyRotation(t *testing.T) {
+	require.NoError(t, SignerSchemes{}.checkKeyRotation())
+	require.NoError(t, SignerSchemes{3: true}.checkKeyRotation())
+	require.NoError(t, SignerSchemes{2: false, 3: true}.checkKeyRotation())
+
+	err := SignerSchemes{2: true, 3: false}.checkKeyRotation()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "v3 signing can not be disabled")
+}
+
+func TestParseMinSDKVersion(t *testing.T) {
+	minSDKVersion, err := parseMinSDKVersion("")
+	require.NoError(t, err)
+	require.Equal(t, 1, minSDKVersion)
+
+	minSDKVersion, err = parseMinSDKVersion("24")
+	require.NoError(t, err)
+	require.Equal(t, 24, minSDKVersion)
+
+	_, err = parseMinSDKVersion("Tiramisu")
+	require.Error(t, err)
+}
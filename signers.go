@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+)
+
+const additionalSignerFileName = "signer-keystore"
+
+// parseAdditionalSigners parses the additional_signers input.
+func parseAdditionalSigners(list string) ([]signingCredentials, error) {
+	return parseCredentialSets(list, "additional signer")
+}
+
+// openAdditionalSigners opens the keystores of the additional signers, in the order of the additional_signers input.
+func openAdditionalSigners(ws *workspace, resolvers keystoreResolvers, vault *vaultClient, cfg configs) ([]openedKeystore, error) {
+	sets, err := parseAdditionalSigners(cfg.AdditionalSigners)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []openedKeystore
+	for i, set := range sets {
+		opened, err := openKeystore(ws, resolvers, vault, set, fmt.Sprintf("%s-%d", additionalSignerFileName, i+1), set.typeInput("automatic"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", set.name, err)
+		}
+		signers = append(signers, opened)
+	}
+	return signers, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-steplib/steps-sign-apk/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAdditionalSigners(t *testing.T) {
+	dir := t.TempDir()
+	var entries []keystore.PrivateKeyEntry
+	for i, alias := range []string{"partner", "store"} {
+		entry, err := keystore.Generate(keystore.GenerateOptions{
+			Alias:             alias,
+			DistinguishedName: "CN=" + alias,
+			KeySpec:           keystore.KeySpecECP256,
+			ValidityDays:      1,
+		})
+		require.NoError(t, err)
+		data, err := keystore.Encode(keystore.TypeJKS, entry, alias+"pass", "")
+		require.NoError(t, err)
+		pth := filepath.Join(dir, alias+".jks")
+		require.NoError(t, os.WriteFile(pth, data, 0600))
+		entries = append(entries, entry)
+
+		envPrefix := []string{"PARTNER", "STORE"}[i]
+		t.Setenv(envPrefix+"_KEYSTORE_URL", "file://"+pth)
+		t.Setenv(envPrefix+"_KEYSTORE_PASSWORD", alias+"pass")
+		t.Setenv(envPrefix+"_KEYSTORE_ALIAS", alias)
+	}
+
+	resolvers := newKeystoreResolvers(downloader{}, s3Config{}, nil)
+
+	t.Log("opens the signers in order")
+	{
+		signers, err := openAdditionalSigners(newTestWorkspace(t), resolvers, nil, configs{AdditionalSigners: `
+PARTNER_KEYSTORE_URL, PARTNER_KEYSTORE_PASSWORD, PARTNER_KEYSTORE_ALIAS
+STORE_KEYSTORE_URL, STORE_KEYSTORE_PASSWORD, STORE_KEYSTORE_ALIAS
+`})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(signers))
+		for i, signer := range signers {
+			require.Equal(t, entries[i].Alias, signer.credentials.alias)
+			require.Equal(t, keystore.NewCertificateInfo(entries[i].Certificate()), signer.helper.CertificateInfo())
+		}
+		require.Equal(t, "additional signer 2 (STORE_KEYSTORE_URL)", signers[1].credentials.name)
+	}
+
+	t.Log("opens the keystores with the type of their set")
+	{
+		signers, err := openAdditionalSigners(newTestWorkspace(t), resolvers, nil, configs{AdditionalSigners: `
+PARTNER_KEYSTORE_URL, PARTNER_KEYSTORE_PASSWORD, PARTNER_KEYSTORE_ALIAS, , jks
+`})
+		require.NoError(t, err)
+		require.Equal(t, keystore.TypeJKS, signers[0].keystoreType)
+
+		_, err = openAdditionalSigners(newTestWorkspace(t), resolvers, nil, configs{AdditionalSigners: `
+PARTNER_KEYSTORE_URL, PARTNER_KEYSTORE_PASSWORD, PARTNER_KEYSTORE_ALIAS, , pkcs12
+`})
+		require.Error(t, err)
+	}
+
+	t.Log("fails with the name of the signer which can not be opened")
+	{
+		t.Setenv("WRONG_KEYSTORE_PASSWORD", "wrong")
+		_, err := openAdditionalSigners(newTestWorkspace(t), resolvers, nil, configs{AdditionalSigners: `
+PARTNER_KEYSTORE_URL, PARTNER_KEYSTORE_PASSWORD, PARTNER_KEYSTORE_ALIAS
+STORE_KEYSTORE_URL, WRONG_KEYSTORE_PASSWORD, STORE_KEYSTORE_ALIAS
+`})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "additional signer 2 (STORE_KEYSTORE_URL)")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+)
+
+const stampKeystoreFileName = "stamp-keystore"
+
+// openSourceStamp opens the keystore of the SourceStamp signer.
+func openSourceStamp(ws *workspace, resolvers keystoreResolvers, vault *vaultClient, cfg configs) (openedKeystore, error) {
+	signer, err := openKeystore(ws, resolvers, vault, stampCredentials(cfg), stampKeystoreFileName, cfg.StampKeystoreType)
+	if err != nil {
+		return openedKeystore{}, fmt.Errorf("stamp keystore: %w", err)
+	}
+	return signer, nil
+}
+
+// stampCredentials returns the credential set of the SourceStamp key.
+func stampCredentials(cfg configs) signingCredentials {
+	return signingCredentials{
+		name:             "stamp_keystore_url",
+		keystoreURL:      string(cfg.StampKeystoreURL),
+		keystorePassword: string(cfg.StampKeystorePassword),
+		alias:            string(cfg.StampKeystoreAlias),
+		keyPassword:      string(cfg.StampPrivateKeyPassword),
+	}
+}
+
+// sourceStampConfiguration returns the apksigner configuration of the SourceStamp signer.
+func sourceStampConfiguration(stamp openedKeystore) SourceStampConfiguration {
+	return NewSourceStampConfiguration(keystoreSignatureConfiguration(stamp), certificateDigest(stamp.helper.CertificateInfo()))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	vaultScheme              = "vault://"
+	defaultVaultAppRoleMount = "approle"
+)
+
+// vaultConfig configures access to a HashiCorp Vault compatible secrets backend.
+type vaultConfig struct {
+	address   string
+	namespace string
+
+	// token is used directly, if empty AppRole login is performed with roleID and secretID.
+	token        string
+	roleID       string
+	secretID     string
+	appRoleMount string
+}
+
+// vaultReference points to a field of a KV v2 secret: vault://<mount>/<path>#<field>
+// The mount is the first path segment, or everything before a double slash for mounts with more segments:
+// vault://<mount>//<path>#<field>
+type vaultReference struct {
+	mount string
+	path  string
+	field string
+}
+
+func (r vaultReference) String() string {
+	return fmt.Sprintf("%s%s#%s", vaultScheme, r.secretPath(), r.field)
+}
+
+// secretPath returns the mount and the path of the secret, separated with a double slash if the mount has more segments.
+func (r vaultReference) secretPath() string {
+	if strings.Contains(r.mount, "/") {
+		return r.mount + "//" + r.path
+	}
+	return r.mount + "/" + r.path
+}
+
+func isVaultReference(s string) bool {
+	_, ok := trimPrefixFold(s, vaultScheme)
+	return ok
+}
+
+func parseVaultReference(s string) (vaultReference, error) {
+	ref, ok := trimPrefixFold(s, vaultScheme)
+	if !ok {
+		return vaultReference{}, fmt.Errorf("not a vault reference, expected format: %s<mount>/<path>#<field>", vaultScheme)
+	}
+
+	secretPath, field, found := strings.Cut(ref, "#")
+	mount, pth, explicitMount := strings.Cut(strings.TrimLeft(secretPath, "/"), "//")
+	if explicitMount {
+		mount, pth = strings.Trim(mount, "/"), strings.Trim(pth, "/")
+	} else {
+		mount, pth, _ = strings.Cut(strings.Trim(secretPath, "/"), "/")
+	}
+	if !found || field == "" || mount == "" || pth == "" {
+		return vaultReference{}, fmt.Errorf("invalid vault reference (%s), expected format: %s<mount>/<path>#<field>", s, vaultScheme)
+	}
+
+	return vaultReference{mount: mount, path: pth, field: field}, nil
+}
+
+// vaultClient reads secrets from the Vault KV v2 HTTP API.
+type vaultClient struct {
+	config     vaultConfig
+	httpClient *http.Client
+
+	token   string
+	secrets map[string]map[string]interface{}
+}
+
+func newVaultClient(config vaultConfig, httpClient *http.Client) *vaultClient {
+	if config.appRoleMount == "" {
+		config.appRoleMount = defaultVaultAppRoleMount
+	}
+
+	return &vaultClient{
+		config:     config,
+		httpClient: httpClient,
+		token:      config.token,
+		secrets:    map[string]map[string]interface{}{},
+	}
+}
+
+// read returns the string value of the referenced field. Secrets are cached, each one is fetched once.
+func (c *vaultClient) read(ref vaultReference) (string, error) {
+	secretKey := ref.secretPath()
+	data, ok := c.secrets[secretKey]
+	if !ok {
+		var err error
+		if data, err = c.readSecret(ref.mount, ref.path); err != nil {
+			return "", err
+		}
+		c.secrets[secretKey] = data
+	}
+
+	value, ok := data[ref.field]
+	if !ok {
+		return "", fmt.Errorf("field (%s) not found in vault secret: %s", ref.field, secretKey)
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field (%s) of vault secret (%s) is not a string", ref.field, secretKey)
+	}
+	return str, nil
+}
+
+func (c *vaultClient) readSecret(mount, pth string) (map[string]interface{}, error) {
+	if err := c.login(); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := c.do(http.MethodGet, "/v1/"+mount+"/data/"+pth, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to read vault secret (%s/%s): %s", mount, pth, err)
+	}
+	if response.Data.Data == nil {
+		return nil, fmt.Errorf("vault secret (%s/%s) has no data, is %s a KV version 2 secrets engine?", mount, pth, mount)
+	}
+	return response.Data.Data, nil
+}
+
+func (c *vaultClient) login() error {
+	if c.token != "" {
+		return nil
+	}
+	if c.config.roleID == "" {
+		return errors.New("no vault token or AppRole credentials provided")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role_id":   c.config.roleID,
+		"secret_id": c.config.secretID,
+	})
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := c.do(http.MethodPost, "/v1/auth/"+c.config.appRoleMount+"/login", body, &response); err != nil {
+		return fmt.Errorf("vault AppRole login failed: %s", err)
+	}
+	if response.Auth.ClientToken == "" {
+		return errors.New("vault AppRole login failed: no client token in the response")
+	}
+
+	log.Printf("Logged in to vault with AppRole")
+	c.token = response.Auth.ClientToken
+	return nil
+}
+
+func (c *vaultClient) do(method, pth string, body []byte, v interface{}) error {
+	if c.config.address == "" {
+		return errors.New("vault address is not set")
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.config.address, "/")+pth, bodyReader)
+	if err != nil {
+		return redactURLError(err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.config.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The response body is not included: it only carries error descriptions, but better safe than sorry.
+		return fmt.Errorf("vault responded with status: %s", resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxKeystoreSize)).Decode(v)
+}
+
+// vaultKeystoreResolver resolves vault://<mount>/<path>#<field> keystore urls.
+// The field either holds the base64 encoded keystore or an url of any other supported scheme.
+type vaultKeystoreResolver struct {
+	client    *vaultClient
+	resolvers keystoreResolvers
+}
+
+func (r vaultKeystoreResolver) resolve(keystoreURL, fileName string, ws *workspace) (string, bool, error) {
+	ref, err := parseVaultReference(keystoreURL)
+	if err != nil {
+		return "", false, err
+	}
+
+	log.Infof("Read keystore from vault: %s", ref)
+	value, err := r.client.read(ref)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Base64 has no ':' in its alphabet, anything with a scheme is an url.
+	if _, _, isURL := strings.Cut(value, ":"); isURL {
+		if isVaultReference(value) {
+			return "", false, errors.New("vault secret holds another vault reference")
+		}
+		return r.resolvers.resolve(value, fileName, ws)
+	}
+
+	content, err := decodeBase64(value)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to base64 decode keystore from vault: %s", err)
+	}
+	keystorePath, err := ws.writeSecret(fileName, content)
+	return keystorePath, true, err
+}
+
+// resolveVaultSecret returns the referenced vault field if value is a vault reference, or value as is.
+func resolveVaultSecret(client *vaultClient, inputName, value string) (string, error) {
+	if !isVaultReference(value) {
+		return value, nil
+	}
+
+	ref, err := parseVaultReference(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", inputName, err)
+	}
+
+	log.Printf("Read %s from vault: %s", inputName, ref)
+	secret, err := client.read(ref)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", inputName, err)
+	}
+	return secret, nil
+}
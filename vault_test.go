@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestVaultServer serves a KV v2 secret at secret/android/signing and AppRole login for role/secret.
+func newTestVaultServer(t *testing.T, data map[string]interface{}) (*httptest.Server, *int32) {
+	var reads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/approle/login":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			if body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"approle-token"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/secret/data/android/signing":
+			if token := r.Header.Get("X-Vault-Token"); token != "token" && token != "approle-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			atomic.AddInt32(&reads, 1)
+			require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": data},
+			}))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server, &reads
+}
+
+func TestParseVaultReference(t *testing.T) {
+	ref, err := parseVaultReference("vault://secret/android/signing#keystore")
+	require.NoError(t, err)
+	require.Equal(t, vaultReference{mount: "secret", path: "android/signing", field: "keystore"}, ref)
+	require.Equal(t, "vault://secret/android/signing#keystore", ref.String())
+
+	t.Log("takes the mount before a double slash")
+	{
+		ref, err := parseVaultReference("vault://kv/team-a//android/signing#keystore")
+		require.NoError(t, err)
+		require.Equal(t, vaultReference{mount: "kv/team-a", path: "android/signing", field: "keystore"}, ref)
+		require.Equal(t, "vault://kv/team-a//android/signing#keystore", ref.String())
+	}
+
+	for _, s := range []string{"vault://secret/android/signing", "vault://secret#keystore", "vault://secret/android/signing#", "vault://kv/team-a//#keystore"} {
+		_, err := parseVaultReference(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestVaultKeystoreResolver(t *testing.T) {
+	server, reads := newTestVaultServer(t, map[string]interface{}{
+		"keystore":     base64.StdEncoding.EncodeToString([]byte("keystore content")),
+		"keystore_url": "data:;base64," + base64.StdEncoding.EncodeToString([]byte("data keystore")),
+		"password":     "store pass",
+		"nested":       "vault://secret/android/signing#keystore",
+	})
+	defer server.Close()
+
+	t.Log("logs in with AppRole and decodes the base64 keystore")
+	{
+		client := newVaultClient(vaultConfig{address: server.URL, roleID: "role", secretID: "secret"}, server.Client())
+		resolvers := newKeystoreResolvers(downloader{}, s3Config{}, client)
+
+		ws := newTestWorkspace(t)
+		pth, temporary, err := resolvers.resolve("vault://secret/android/signing#keystore", keystoreFileName, ws)
+		require.NoError(t, err)
+		require.True(t, temporary)
+		requireSecretFile(t, pth, []byte("keystore content"))
+
+		t.Log("resolves an url stored in the secret")
+		pth, _, err = resolvers.resolve("vault://secret/android/signing#keystore_url", keystoreFileName, ws)
+		require.NoError(t, err)
+		requireSecretFile(t, pth, []byte("data keystore"))
+
+		t.Log("rejects nested vault references")
+		_, _, err = resolvers.resolve("vault://secret/android/signing#nested", keystoreFileName, ws)
+		require.EqualError(t, err, "vault secret holds another vault reference")
+
+		password, err := resolveVaultSecret(client, "keystore_password", "vault://secret/android/signing#password")
+		require.NoError(t, err)
+		require.Equal(t, "store pass", password)
+
+		_, err = resolveVaultSecret(client, "keystore_password", "vault://secret/android/signing#missing")
+		require.EqualError(t, err, "keystore_password: field (missing) not found in vault secret: secret/android/signing")
+
+		require.Equal(t, int32(1), atomic.LoadInt32(reads))
+	}
+
+	t.Log("leaves plain values as is")
+	{
+		value, err := resolveVaultSecret(nil, "keystore_password", "plain")
+		require.NoError(t, err)
+		require.Equal(t, "plain", value)
+	}
+
+	t.Log("fails without credentials or with a rejected token")
+	{
+		client := newVaultClient(vaultConfig{address: server.URL}, server.Client())
+		_, err := resolveVaultSecret(client, "keystore_alias", "vault://secret/android/signing#password")
+		require.EqualError(t, err, "keystore_alias: no vault token or AppRole credentials provided")
+
+		client = newVaultClient(vaultConfig{address: server.URL, token: "invalid"}, server.Client())
+		_, err = resolveVaultSecret(client, "keystore_alias", "vault://secret/android/signing#password")
+		require.EqualError(t, err, "keystore_alias: failed to read vault secret (secret/android/signing): vault responded with status: 403 Forbidden")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const workspaceDirMode = 0700
+
+// stepWorkspace is cleaned up by failf, as os.Exit skips deferred calls.
+var stepWorkspace *workspace
+
+// workspace owns the temporary directory of the Step: downloaded keystores and intermediate build artifacts.
+type workspace struct {
+	dir  string
+	keep bool
+
+	mu      sync.Mutex
+	secrets []string
+	once    sync.Once
+}
+
+func newWorkspace(prefix string, keep bool) (*workspace, error) {
+	dir, err := pathutil.NormalizedOSTempDirPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, workspaceDirMode); err != nil {
+		return nil, err
+	}
+
+	return &workspace{
+		dir:  dir,
+		keep: keep,
+	}, nil
+}
+
+// path returns the path of name inside the workspace.
+func (w *workspace) path(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+// writeSecret writes content to name inside the workspace, readable only by the current user.
+// The file is overwritten before it is removed on cleanup.
+func (w *workspace) writeSecret(name string, content []byte) (string, error) {
+	pth := w.path(name)
+	w.trackSecret(pth)
+	return pth, writeSecretFile(pth, content)
+}
+
+// trackSecret marks a file to be overwritten before it is removed on cleanup.
+func (w *workspace) trackSecret(pth string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.secrets = append(w.secrets, pth)
+}
+
+// cleanupOnSignal cleans up the workspace and exits when the Step is interrupted or terminated.
+func (w *workspace) cleanupOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		log.Warnf("Received %s signal", sig)
+		w.cleanup()
+
+		exitCode := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			exitCode = 128 + int(s)
+		}
+		os.Exit(exitCode)
+	}()
+}
+
+// cleanup removes the workspace, unless it should be kept for debugging.
+// It is safe to call multiple times and from multiple goroutines.
+func (w *workspace) cleanup() {
+	w.once.Do(func() {
+		if w.keep {
+			log.Warnf("keep_intermediates is enabled, the keystore and the intermediate build artifacts are kept at: %s", w.dir)
+			return
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		for _, pth := range w.secrets {
+			if err := shredFile(pth); err != nil {
+				log.Warnf("Failed to overwrite %s: %s", pth, err)
+			}
+		}
+
+		if err := os.RemoveAll(w.dir); err != nil {
+			log.Warnf("Failed to remove temporary directory (%s): %s", w.dir, err)
+		}
+	})
+}
+
+// shredFile overwrites the content of the file with zeros and removes it.
+func shredFile(pth string) error {
+	info, err := os.Stat(pth)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(pth, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, copyErr := io.CopyN(f, zeroReader{}, info.Size())
+	syncErr := f.Sync()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+
+	if err := os.Remove(pth); err != nil {
+		return fmt.Errorf("failed to remove file: %s", err)
+	}
+	return nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorkspace(t *testing.T) *workspace {
+	ws, err := newWorkspace("workspace-test", false)
+	require.NoError(t, err)
+	t.Cleanup(ws.cleanup)
+	return ws
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Log("creates a private directory and secret files")
+	{
+		ws := newTestWorkspace(t)
+
+		info, err := os.Stat(ws.dir)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(workspaceDirMode), info.Mode().Perm())
+
+		pth, err := ws.writeSecret("keystore", []byte("secret"))
+		require.NoError(t, err)
+		info, err = os.Stat(pth)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(secretFileMode), info.Mode().Perm())
+	}
+
+	t.Log("overwrites secrets and removes the directory")
+	{
+		ws := newTestWorkspace(t)
+
+		pth, err := ws.writeSecret("keystore", []byte("secret"))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(ws.path("unsigned.apk"), []byte("apk"), 0600))
+
+		// The hard link keeps the content reachable after the workspace is removed.
+		link := filepath.Join(t.TempDir(), "link")
+		require.NoError(t, os.Link(pth, link))
+
+		ws.cleanup()
+		ws.cleanup()
+
+		require.NoDirExists(t, ws.dir)
+		content, err := ioutil.ReadFile(link)
+		require.NoError(t, err)
+		require.Equal(t, make([]byte, len("secret")), content)
+	}
+
+	t.Log("keeps everything when requested")
+	{
+		ws, err := newWorkspace("workspace-test", true)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, os.RemoveAll(ws.dir))
+		}()
+
+		pth, err := ws.writeSecret("keystore", []byte("secret"))
+		require.NoError(t, err)
+
+		ws.cleanup()
+
+		content, err := ioutil.ReadFile(pth)
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(content))
+	}
+}